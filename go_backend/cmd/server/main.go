@@ -11,11 +11,22 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
+	"go_backend/internal/analytics"
+	"go_backend/internal/geo"
+	"go_backend/internal/handlers/auth"
+	"go_backend/internal/mailer"
+	"go_backend/internal/security"
 	"go_backend/internal/storage"
 	"go_backend/router"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -40,6 +51,21 @@ func main() {
 		log.Fatalf("server: Redis initialization failed: %v", err)
 	}
 
+	// Load JWT signing keys so a bad configuration fails here rather than on
+	// the first login.
+	if err := security.LoadKeys(); err != nil {
+		log.Fatalf("server: %v", err)
+	}
+
+	// Configure outgoing email.
+	mailer.SetDefault(mailer.FromEnv())
+
+	// Load the GeoIP database for geo rules and visit locations.
+	geo.SetDefault(geo.FromEnv())
+
+	// Start background workers.
+	analytics.Start(analytics.ConfigFromEnv())
+
 	// Read configuration values (Docker-friendly defaults).
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -63,8 +89,34 @@ func main() {
 	engine = router.SetupRouter(engine)
 
 	// Start the HTTP server.
-	log.Printf("server: starting on port http://localhost:%s", port)
-	if err := engine.Run(":" + port); err != nil {
-		log.Fatalf("server: startup failed: %v", err)
+	srv := &http.Server{
+		Addr:    ":" + port,
+		Handler: engine,
+	}
+	go func() {
+		log.Printf("server: starting on port http://localhost:%s", port)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("server: startup failed: %v", err)
+		}
+	}()
+
+	// Wait for an interrupt, then stop accepting requests and drain
+	// background workers before exiting.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+	log.Println("server: shutting down")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("server: graceful shutdown failed: %v", err)
+	}
+	if err := analytics.Shutdown(shutdownCtx); err != nil {
+		log.Printf("server: analytics drain incomplete: %v", err)
+	}
+	if err := auth.WaitForMail(shutdownCtx); err != nil {
+		log.Printf("server: pending emails not sent: %v", err)
 	}
 }
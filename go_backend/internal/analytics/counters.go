@@ -0,0 +1,96 @@
+package analytics
+
+import (
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// clickDelta is the pending click_count increment for one link.
+type clickDelta struct {
+	count int64
+	last  time.Time
+}
+
+// clickCounters aggregates per-link click increments in memory so that
+// urls.click_count and urls.last_clicked_at are updated periodically with
+// one statement instead of once per visit.
+type clickCounters struct {
+	mu      sync.Mutex
+	pending map[string]clickDelta
+}
+
+func newClickCounters() *clickCounters {
+	return &clickCounters{pending: make(map[string]clickDelta)}
+}
+
+// add records the visits of a successfully persisted batch.
+func (cc *clickCounters) add(batch []Visit) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	for _, v := range batch {
+		d := cc.pending[v.URLID]
+		d.count++
+		if v.VisitedAt.After(d.last) {
+			d.last = v.VisitedAt
+		}
+		cc.pending[v.URLID] = d
+	}
+}
+
+// merge puts deltas back after a failed flush so they are retried.
+func (cc *clickCounters) merge(deltas map[string]clickDelta) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	for id, d := range deltas {
+		cur := cc.pending[id]
+		cur.count += d.count
+		if d.last.After(cur.last) {
+			cur.last = d.last
+		}
+		cc.pending[id] = cur
+	}
+}
+
+// take swaps out and returns all pending deltas.
+func (cc *clickCounters) take() map[string]clickDelta {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	deltas := cc.pending
+	cc.pending = make(map[string]clickDelta)
+	return deltas
+}
+
+// flush applies all pending deltas in a single UPDATE. On failure the
+// deltas are merged back for the next attempt.
+func (cc *clickCounters) flush(db *sql.DB) error {
+	deltas := cc.take()
+	if len(deltas) == 0 {
+		return nil
+	}
+
+	ids := make([]string, 0, len(deltas))
+	counts := make([]int64, 0, len(deltas))
+	lasts := make([]string, 0, len(deltas))
+	for id, d := range deltas {
+		ids = append(ids, id)
+		counts = append(counts, d.count)
+		lasts = append(lasts, d.last.Format("2006-01-02 15:04:05.999999"))
+	}
+
+	_, err := db.Exec(`
+		UPDATE urls
+		SET click_count = COALESCE(urls.click_count, 0) + v.n,
+		    last_clicked_at = GREATEST(COALESCE(urls.last_clicked_at, v.t), v.t)
+		FROM (
+			SELECT unnest($1::text[]) AS id, unnest($2::bigint[]) AS n, unnest($3::timestamp[]) AS t
+		) AS v
+		WHERE urls.id = v.id`,
+		pq.Array(ids), pq.Array(counts), pq.Array(lasts))
+	if err != nil {
+		cc.merge(deltas)
+	}
+	return err
+}
@@ -0,0 +1,284 @@
+package analytics
+
+import (
+	"context"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go_backend/internal/storage"
+)
+
+// maxBatchSize keeps a single INSERT well below PostgreSQL's 65535
+// bind-parameter limit.
+const maxBatchSize = 65535 / visitColumns
+
+// Config controls the size and timing of the click-event pipeline.
+type Config struct {
+	QueueSize            int           // visits buffered in memory before backpressure applies
+	Workers              int           // goroutines draining the queue
+	BatchSize            int           // visits written per INSERT
+	FlushInterval        time.Duration // max time a partial batch waits before being written
+	CounterFlushInterval time.Duration // how often click_count/last_clicked_at are updated
+	EnqueueTimeout       time.Duration // how long Record waits on a full queue before dropping
+}
+
+// ConfigFromEnv reads the pipeline configuration from ANALYTICS_* environment
+// variables, falling back to defaults suited to a single small instance.
+func ConfigFromEnv() Config {
+	return Config{
+		QueueSize:            envInt("ANALYTICS_QUEUE_SIZE", 10000),
+		Workers:              envInt("ANALYTICS_WORKERS", 2),
+		BatchSize:            envInt("ANALYTICS_BATCH_SIZE", 500),
+		FlushInterval:        time.Duration(envInt("ANALYTICS_FLUSH_INTERVAL_MS", 1000)) * time.Millisecond,
+		CounterFlushInterval: time.Duration(envInt("ANALYTICS_COUNTER_FLUSH_INTERVAL_MS", 5000)) * time.Millisecond,
+		EnqueueTimeout:       time.Duration(envInt("ANALYTICS_ENQUEUE_TIMEOUT_MS", 2)) * time.Millisecond,
+	}
+}
+
+// Stats is a snapshot of the pipeline counters.
+type Stats struct {
+	Enqueued   uint64 `json:"enqueued"`
+	Dropped    uint64 `json:"dropped"`
+	Persisted  uint64 `json:"persisted"`
+	Failed     uint64 `json:"failed"`
+	QueueDepth int    `json:"queue_depth"`
+	QueueSize  int    `json:"queue_size"`
+}
+
+// pipeline is a bounded queue drained by a pool of batching workers plus a
+// goroutine that periodically flushes aggregated click counters.
+type pipeline struct {
+	cfg      Config
+	queue    chan Visit
+	counters *clickCounters
+
+	// mu guards closed; Record holds it for reading while sending so that
+	// Shutdown never closes the queue under an in-flight send.
+	mu     sync.RWMutex
+	closed bool
+
+	workers sync.WaitGroup
+	stop    chan struct{}
+	flushed chan struct{}
+
+	enqueued  atomic.Uint64
+	dropped   atomic.Uint64
+	persisted atomic.Uint64
+	failed    atomic.Uint64
+}
+
+// active is the running pipeline, or nil when Start has not been called.
+var active atomic.Pointer[pipeline]
+
+// Start launches the click-event pipeline. It should be called once from
+// cmd/server/main.go and paired with Shutdown.
+func Start(cfg Config) {
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 1
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = 1
+	}
+	if cfg.BatchSize <= 0 || cfg.BatchSize > maxBatchSize {
+		cfg.BatchSize = maxBatchSize
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = time.Second
+	}
+	if cfg.CounterFlushInterval <= 0 {
+		cfg.CounterFlushInterval = 5 * time.Second
+	}
+
+	p := &pipeline{
+		cfg:      cfg,
+		queue:    make(chan Visit, cfg.QueueSize),
+		counters: newClickCounters(),
+		stop:     make(chan struct{}),
+		flushed:  make(chan struct{}),
+	}
+	for i := 0; i < cfg.Workers; i++ {
+		p.workers.Add(1)
+		go p.work()
+	}
+	go p.flushCounters()
+
+	active.Store(p)
+	log.Printf("analytics: pipeline started (workers=%d, queue=%d, batch=%d)",
+		cfg.Workers, cfg.QueueSize, cfg.BatchSize)
+}
+
+// Record queues a visit for persistence. When the queue is full it waits at
+// most Config.EnqueueTimeout for room and then drops the visit, so a slow
+// database can never stall redirects. Visits are also dropped when the
+// pipeline is not running.
+func Record(v Visit) {
+	p := active.Load()
+	if p == nil {
+		return
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		p.dropped.Add(1)
+		return
+	}
+
+	select {
+	case p.queue <- v:
+		p.enqueued.Add(1)
+		return
+	default:
+	}
+
+	if p.cfg.EnqueueTimeout > 0 {
+		timer := time.NewTimer(p.cfg.EnqueueTimeout)
+		defer timer.Stop()
+		select {
+		case p.queue <- v:
+			p.enqueued.Add(1)
+			return
+		case <-timer.C:
+		}
+	}
+	p.dropped.Add(1)
+}
+
+// Shutdown stops accepting visits, drains the queue, writes the remaining
+// batches and flushes click counters. It returns ctx.Err() if draining does
+// not finish before ctx is done.
+func Shutdown(ctx context.Context) error {
+	p := active.Load()
+	if p == nil {
+		return nil
+	}
+
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.queue)
+	}
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.workers.Wait()
+		close(p.stop)
+		<-p.flushed
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		s := CurrentStats()
+		log.Printf("analytics: pipeline drained (persisted=%d, failed=%d, dropped=%d)",
+			s.Persisted, s.Failed, s.Dropped)
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// CurrentStats returns the counters of the running pipeline.
+func CurrentStats() Stats {
+	p := active.Load()
+	if p == nil {
+		return Stats{}
+	}
+	return Stats{
+		Enqueued:   p.enqueued.Load(),
+		Dropped:    p.dropped.Load(),
+		Persisted:  p.persisted.Load(),
+		Failed:     p.failed.Load(),
+		QueueDepth: len(p.queue),
+		QueueSize:  cap(p.queue),
+	}
+}
+
+// work collects visits into batches and writes each batch once it is full
+// or FlushInterval has passed, whichever comes first.
+func (p *pipeline) work() {
+	defer p.workers.Done()
+
+	batch := make([]Visit, 0, p.cfg.BatchSize)
+	ticker := time.NewTicker(p.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case v, ok := <-p.queue:
+			if !ok {
+				p.writeBatch(batch)
+				return
+			}
+			batch = append(batch, v)
+			if len(batch) >= p.cfg.BatchSize {
+				p.writeBatch(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			p.writeBatch(batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+// writeBatch persists a batch and feeds the click counters on success.
+func (p *pipeline) writeBatch(batch []Visit) {
+	if len(batch) == 0 {
+		return
+	}
+
+	markVisitors(batch)
+	locateVisitors(batch)
+	n, err := insertVisits(storage.GetPostgres(), batch)
+	if err != nil {
+		p.failed.Add(uint64(len(batch)))
+		log.Printf("analytics: failed to insert %d visits: %v", len(batch), err)
+		return
+	}
+	p.persisted.Add(uint64(n))
+	p.counters.add(batch)
+}
+
+// flushCounters periodically applies aggregated click counts and reports
+// newly dropped visits. It performs a final flush once stop is closed.
+func (p *pipeline) flushCounters() {
+	defer close(p.flushed)
+
+	ticker := time.NewTicker(p.cfg.CounterFlushInterval)
+	defer ticker.Stop()
+
+	var lastDropped uint64
+	for {
+		select {
+		case <-ticker.C:
+			if err := p.counters.flush(storage.GetPostgres()); err != nil {
+				log.Printf("analytics: failed to update click counters: %v", err)
+			}
+			if dropped := p.dropped.Load(); dropped > lastDropped {
+				log.Printf("analytics: %d visits dropped under backpressure (total %d)",
+					dropped-lastDropped, dropped)
+				lastDropped = dropped
+			}
+		case <-p.stop:
+			if err := p.counters.flush(storage.GetPostgres()); err != nil {
+				log.Printf("analytics: final click counter update failed: %v", err)
+			}
+			return
+		}
+	}
+}
+
+// envInt reads an environment variable as integer or returns fallback.
+func envInt(env string, fallback int) int {
+	v, err := strconv.Atoi(os.Getenv(env))
+	if err != nil {
+		return fallback
+	}
+	return v
+}
@@ -0,0 +1,240 @@
+package analytics
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Supported time-series bucket sizes.
+const (
+	IntervalHour = "hour"
+	IntervalDay  = "day"
+	IntervalWeek = "week"
+)
+
+// maxBuckets bounds the size of a single time-series response.
+const maxBuckets = 2000
+
+// ErrInvalidRange is returned when a report range is empty, inverted or
+// would produce too many buckets.
+var ErrInvalidRange = errors.New("invalid analytics range")
+
+// Range selects the visits included in a report: From is inclusive, To is
+// exclusive.
+type Range struct {
+	From     time.Time
+	To       time.Time
+	Interval string
+}
+
+// Validate checks the range bounds and the bucket count for its interval.
+func (r Range) Validate() error {
+	step, ok := intervalStep(r.Interval)
+	if !ok || !r.From.Before(r.To) {
+		return ErrInvalidRange
+	}
+	if r.To.Sub(r.From)/step > maxBuckets {
+		return ErrInvalidRange
+	}
+	return nil
+}
+
+// intervalStep returns the duration of one bucket.
+func intervalStep(interval string) (time.Duration, bool) {
+	switch interval {
+	case IntervalHour:
+		return time.Hour, true
+	case IntervalDay:
+		return 24 * time.Hour, true
+	case IntervalWeek:
+		return 7 * 24 * time.Hour, true
+	}
+	return 0, false
+}
+
+// Bucket is the number of clicks in one time-series interval.
+type Bucket struct {
+	Start  time.Time `json:"bucket"`
+	Clicks int64     `json:"clicks"`
+	Unique int64     `json:"unique"`
+}
+
+// Count is one entry of a breakdown, e.g. a referrer and its click count.
+type Count struct {
+	Value  string `json:"value"`
+	Clicks int64  `json:"clicks"`
+}
+
+// Summary aggregates the visits of a link, or a group of links, over a
+// range.
+type Summary struct {
+	TotalClicks    int64   `json:"total_clicks"`
+	UniqueVisitors int64   `json:"unique_visitors"`
+	Links          []Count `json:"links,omitempty"` // top slugs, for tag and folder scopes
+	Referrers      []Count `json:"referrers"`
+	Countries      []Count `json:"countries"`
+	Cities         []Count `json:"cities"`
+	Browsers       []Count `json:"browsers"`
+	OS             []Count `json:"os"`
+	Devices        []Count `json:"devices"`
+	Variants       []Count `json:"variants"` // A/B variants, "(none)" for other visits
+}
+
+// Scope selects the links whose visits a report covers.
+type Scope struct {
+	// filter is a condition on a url_id column: %[1]s is the column and
+	// %[2]s the placeholder for arg.
+	filter string
+	arg    string
+	group  bool
+}
+
+// LinkScope covers a single link.
+func LinkScope(urlID string) Scope {
+	return Scope{filter: "%[1]s = %[2]s", arg: urlID}
+}
+
+// TagScope covers all links carrying a tag.
+func TagScope(tagID string) Scope {
+	return Scope{filter: "%[1]s IN (SELECT url_id FROM url_tags WHERE tag_id = %[2]s)", arg: tagID, group: true}
+}
+
+// FolderScope covers all links in a folder.
+func FolderScope(folderID string) Scope {
+	return Scope{filter: "%[1]s IN (SELECT id FROM urls WHERE folder_id = %[2]s)", arg: folderID, group: true}
+}
+
+// where returns the scope's condition on column, using placeholder for its
+// argument.
+func (s Scope) where(column, placeholder string) string {
+	return fmt.Sprintf(s.filter, column, placeholder)
+}
+
+// breakdowns maps url_visits columns to the Summary field they fill and the
+// label used for empty values. Column names come only from this table and
+// are therefore safe to interpolate into SQL.
+var breakdowns = []struct {
+	column string
+	empty  string
+	field  func(*Summary) *[]Count
+}{
+	{"referer", "(direct)", func(s *Summary) *[]Count { return &s.Referrers }},
+	{"country", "(unknown)", func(s *Summary) *[]Count { return &s.Countries }},
+	{"city", "(unknown)", func(s *Summary) *[]Count { return &s.Cities }},
+	{"browser", "(unknown)", func(s *Summary) *[]Count { return &s.Browsers }},
+	{"os", "(unknown)", func(s *Summary) *[]Count { return &s.OS }},
+	{"device", "(unknown)", func(s *Summary) *[]Count { return &s.Devices }},
+	{"variant", "(none)", func(s *Summary) *[]Count { return &s.Variants }},
+}
+
+// TimeSeries returns click and unique-visitor counts for the links in scope
+// bucketed by r.Interval. Buckets without visits are included with zero
+// counts.
+func TimeSeries(db *sql.DB, scope Scope, r Range) ([]Bucket, error) {
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+
+	step := "1 " + r.Interval
+	rows, err := db.Query(`
+		SELECT b.bucket,
+		       COUNT(v.url_id),
+		       COUNT(v.url_id) FILTER (WHERE v.is_unique)
+		FROM generate_series(date_trunc($3, $1::timestamp), $2::timestamp - interval '1 microsecond', $4::interval) AS b(bucket)
+		LEFT JOIN url_visits v
+		       ON `+scope.where("v.url_id", "$5")+`
+		      AND v.visited_at >= GREATEST(b.bucket, $1::timestamp)
+		      AND v.visited_at <  LEAST(b.bucket + $4::interval, $2::timestamp)
+		GROUP BY b.bucket
+		ORDER BY b.bucket`,
+		r.From, r.To, r.Interval, step, scope.arg)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	buckets := []Bucket{}
+	for rows.Next() {
+		var b Bucket
+		if err := rows.Scan(&b.Start, &b.Clicks, &b.Unique); err != nil {
+			return nil, err
+		}
+		buckets = append(buckets, b)
+	}
+	return buckets, rows.Err()
+}
+
+// Summarize returns total and unique visitors for the links in scope plus
+// the top limit values of each breakdown dimension. Tag and folder scopes
+// also list their most visited links.
+func Summarize(db *sql.DB, scope Scope, r Range, limit int) (Summary, error) {
+	var s Summary
+	if err := r.Validate(); err != nil {
+		return s, err
+	}
+
+	err := db.QueryRow(`
+		SELECT COUNT(*), COUNT(*) FILTER (WHERE is_unique)
+		FROM url_visits
+		WHERE `+scope.where("url_id", "$1")+` AND visited_at >= $2 AND visited_at < $3`,
+		scope.arg, r.From, r.To).Scan(&s.TotalClicks, &s.UniqueVisitors)
+	if err != nil {
+		return s, err
+	}
+
+	if scope.group {
+		s.Links, err = countRows(db.Query(`
+			SELECT u.slug, COUNT(*) AS clicks
+			FROM url_visits v
+			JOIN urls u ON u.id = v.url_id
+			WHERE `+scope.where("v.url_id", "$1")+` AND v.visited_at >= $2 AND v.visited_at < $3
+			GROUP BY u.slug
+			ORDER BY clicks DESC, u.slug
+			LIMIT $4`,
+			scope.arg, r.From, r.To, limit))
+		if err != nil {
+			return s, fmt.Errorf("links breakdown: %w", err)
+		}
+	}
+
+	for _, b := range breakdowns {
+		counts, err := topValues(db, b.column, b.empty, scope, r, limit)
+		if err != nil {
+			return s, fmt.Errorf("%s breakdown: %w", b.column, err)
+		}
+		*b.field(&s) = counts
+	}
+	return s, nil
+}
+
+// topValues returns the most frequent values of column for scope in r.
+func topValues(db *sql.DB, column, empty string, scope Scope, r Range, limit int) ([]Count, error) {
+	return countRows(db.Query(fmt.Sprintf(`
+		SELECT COALESCE(NULLIF(%s, ''), $1) AS value, COUNT(*) AS clicks
+		FROM url_visits
+		WHERE %s AND visited_at >= $3 AND visited_at < $4
+		GROUP BY 1
+		ORDER BY clicks DESC, value
+		LIMIT $5`, column, scope.where("url_id", "$2")),
+		empty, scope.arg, r.From, r.To, limit))
+}
+
+// countRows reads (value, clicks) rows into Counts.
+func countRows(rows *sql.Rows, err error) ([]Count, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := []Count{}
+	for rows.Next() {
+		var c Count
+		if err := rows.Scan(&c.Value, &c.Clicks); err != nil {
+			return nil, err
+		}
+		counts = append(counts, c)
+	}
+	return counts, rows.Err()
+}
@@ -0,0 +1,147 @@
+// Package analytics records shortlink click events into the url_visits table.
+//
+// Redirect handlers only build a Visit and hand it to Record, which never
+// blocks for long. A background pipeline performs the Redis unique-visitor
+// checks and batched PostgreSQL writes, keeping the redirect hot path free
+// of database I/O.
+package analytics
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"go_backend/internal/geo"
+	"go_backend/internal/storage"
+	"go_backend/internal/utils"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	// uniqueWindow is how long a visitor is considered "seen" for a link.
+	uniqueWindow = 24 * time.Hour
+
+	visitorKeyPrefix = "visitor:"
+
+	// visitColumns is the number of bound parameters per url_visits row.
+	visitColumns = 13
+)
+
+// Visit is a single click on a shortlink.
+type Visit struct {
+	URLID     string
+	VisitedAt time.Time
+	IPAddress string
+	Referer   string
+	UserAgent string
+	Country   string
+	Region    string
+	City      string
+	Browser   string
+	OS        string
+	Device    string
+	Variant   string // A/B variant the visitor was sent to, if any
+	IsUnique  bool
+}
+
+// NewVisit builds a Visit for urlID from the incoming redirect request.
+// The client IP is passed in so callers can use gin's trusted-proxy logic.
+func NewVisit(r *http.Request, urlID, clientIP string) Visit {
+	ua := r.UserAgent()
+	info := utils.ParseUserAgent(ua)
+	return Visit{
+		URLID:     urlID,
+		VisitedAt: time.Now().UTC(),
+		IPAddress: clientIP,
+		Referer:   r.Referer(),
+		UserAgent: ua,
+		Browser:   info.Browser,
+		OS:        info.OS,
+		Device:    info.Device,
+	}
+}
+
+// visitorKey returns the Redis key identifying a visitor of a link.
+func visitorKey(v Visit) string {
+	sum := sha256.Sum256([]byte(v.IPAddress + "|" + v.UserAgent))
+	return visitorKeyPrefix + v.URLID + ":" + hex.EncodeToString(sum[:16])
+}
+
+// markVisitors sets IsUnique on every visit whose IP/User-Agent pair has not
+// been seen for the same link within uniqueWindow. All checks for a batch
+// share one Redis round trip; on Redis errors visits are left non-unique.
+func markVisitors(batch []Visit) {
+	if storage.RedisClient == nil || len(batch) == 0 {
+		return
+	}
+
+	pipe := storage.RedisClient.Pipeline()
+	cmds := make([]*redis.BoolCmd, len(batch))
+	for i := range batch {
+		cmds[i] = pipe.SetNX(storage.Ctx, visitorKey(batch[i]), 1, uniqueWindow)
+	}
+	_, _ = pipe.Exec(storage.Ctx)
+
+	for i, cmd := range cmds {
+		ok, err := cmd.Result()
+		batch[i].IsUnique = err == nil && ok
+	}
+}
+
+// locateVisitors fills in the location of visits from their IP address with
+// the default geo resolver. The lookup runs here rather than in NewVisit so
+// that redirects do not wait for it.
+func locateVisitors(batch []Visit) {
+	for i := range batch {
+		v := &batch[i]
+		if v.Country != "" {
+			continue
+		}
+		loc := geo.LookupString(v.IPAddress)
+		v.Country, v.Region, v.City = loc.Country, loc.Region, loc.City
+	}
+}
+
+// insertVisits writes a batch of visits with a single multi-row INSERT.
+// Rows belonging to links deleted since the click are filtered out by the
+// join, so one stale event cannot fail the whole batch.
+// It returns the number of rows inserted.
+func insertVisits(db *sql.DB, batch []Visit) (int64, error) {
+	if len(batch) == 0 {
+		return 0, nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString(`
+		INSERT INTO url_visits
+			(url_id, visited_at, ip_address, referer, user_agent, country, region, city, browser, os, device, variant, is_unique)
+		SELECT v.url_id, v.visited_at, v.ip_address, v.referer, v.user_agent, v.country,
+		       v.region, v.city, v.browser, v.os, v.device, NULLIF(v.variant, ''), v.is_unique
+		FROM (VALUES `)
+
+	args := make([]interface{}, 0, len(batch)*visitColumns)
+	for i, v := range batch {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		n := i * visitColumns
+		fmt.Fprintf(&sb, "($%d, $%d::timestamp, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d::boolean)",
+			n+1, n+2, n+3, n+4, n+5, n+6, n+7, n+8, n+9, n+10, n+11, n+12, n+13)
+		args = append(args,
+			v.URLID, v.VisitedAt, v.IPAddress, v.Referer, v.UserAgent,
+			v.Country, v.Region, v.City, v.Browser, v.OS, v.Device, v.Variant, v.IsUnique)
+	}
+	sb.WriteString(`) AS v(url_id, visited_at, ip_address, referer, user_agent, country, region, city, browser, os, device, variant, is_unique)
+		JOIN urls u ON u.id = v.url_id`)
+
+	res, err := db.Exec(sb.String(), args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
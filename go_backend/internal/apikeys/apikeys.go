@@ -0,0 +1,237 @@
+// Package apikeys manages personal API keys, which let scripts call the API
+// without a browser session.
+//
+// Keys look like "sk_<43 url-safe characters>". Only their SHA-256 hash is
+// stored; the key itself is shown once, when it is created.
+package apikeys
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"go_backend/internal/security"
+	"go_backend/internal/storage"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Prefix marks a bearer credential as an API key rather than a JWT.
+const Prefix = "sk_"
+
+// Scopes a key can be granted. Read allows GET requests, write allows
+// everything else.
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+)
+
+// MaxKeysPerUser caps the number of active keys a user may hold.
+const MaxKeysPerUser = 20
+
+const (
+	cacheKeyPrefix    = "apikey:"
+	usedKeyPrefix     = "apikey_used:"
+	cacheTTL          = 5 * time.Minute
+	lastUsedInterval  = time.Minute
+	displayPrefixSize = len(Prefix) + 6
+)
+
+var (
+	// ErrNotFound is returned for unknown keys and keys of other users.
+	ErrNotFound = errors.New("api key not found")
+	// ErrInvalidKey is returned when a presented key is unknown or revoked.
+	ErrInvalidKey = errors.New("invalid api key")
+	// ErrInvalidScope is returned for scopes other than read and write.
+	ErrInvalidScope = errors.New("scopes must be read and/or write")
+	// ErrTooManyKeys is returned when the user already has MaxKeysPerUser keys.
+	ErrTooManyKeys = errors.New("too many api keys")
+)
+
+// Key is an API key as stored, without its secret.
+type Key struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"-"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+// Allows reports whether the key grants scope.
+func (k Key) Allows(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// NormalizeScopes validates and de-duplicates scopes, defaulting to both.
+func NormalizeScopes(scopes []string) ([]string, error) {
+	if len(scopes) == 0 {
+		return []string{ScopeRead, ScopeWrite}, nil
+	}
+	var read, write bool
+	for _, s := range scopes {
+		switch strings.ToLower(strings.TrimSpace(s)) {
+		case ScopeRead:
+			read = true
+		case ScopeWrite:
+			write = true
+		default:
+			return nil, ErrInvalidScope
+		}
+	}
+	var out []string
+	if read {
+		out = append(out, ScopeRead)
+	}
+	if write {
+		out = append(out, ScopeWrite)
+	}
+	return out, nil
+}
+
+// Create issues a new key for userID and returns it together with the
+// secret, which cannot be recovered later.
+func Create(db *sql.DB, userID, name string, scopes []string) (Key, string, error) {
+	var active int
+	err := db.QueryRow(
+		`SELECT COUNT(*) FROM api_keys WHERE user_id = $1 AND revoked_at IS NULL`, userID,
+	).Scan(&active)
+	if err != nil {
+		return Key{}, "", err
+	}
+	if active >= MaxKeysPerUser {
+		return Key{}, "", ErrTooManyKeys
+	}
+
+	token, _, err := security.NewOpaqueToken()
+	if err != nil {
+		return Key{}, "", err
+	}
+	secret := Prefix + token
+
+	k := Key{
+		ID:     uuid.NewString(),
+		UserID: userID,
+		Name:   name,
+		Prefix: secret[:displayPrefixSize],
+		Scopes: scopes,
+	}
+	err = db.QueryRow(`
+		INSERT INTO api_keys (id, user_id, name, prefix, key_hash, scopes)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING created_at`,
+		k.ID, userID, name, k.Prefix, security.HashToken(secret), pq.Array(scopes),
+	).Scan(&k.CreatedAt)
+	if err != nil {
+		return Key{}, "", err
+	}
+	return k, secret, nil
+}
+
+// List returns the user's active keys, newest first.
+func List(db *sql.DB, userID string) ([]Key, error) {
+	rows, err := db.Query(`
+		SELECT id, name, prefix, scopes, created_at, last_used_at
+		FROM api_keys
+		WHERE user_id = $1 AND revoked_at IS NULL
+		ORDER BY created_at DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []Key{}
+	for rows.Next() {
+		k := Key{UserID: userID}
+		var lastUsed sql.NullTime
+		if err := rows.Scan(&k.ID, &k.Name, &k.Prefix, pq.Array(&k.Scopes), &k.CreatedAt, &lastUsed); err != nil {
+			return nil, err
+		}
+		if lastUsed.Valid {
+			k.LastUsedAt = &lastUsed.Time
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+// Revoke disables the key immediately. It returns ErrNotFound if the key
+// does not exist, is already revoked, or belongs to another user.
+func Revoke(db *sql.DB, userID, id string) error {
+	var hash string
+	err := db.QueryRow(`
+		UPDATE api_keys SET revoked_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+		RETURNING key_hash`, id, userID).Scan(&hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return storage.RedisClient.Del(storage.Ctx, cacheKeyPrefix+hash).Err()
+}
+
+// Authenticate resolves a presented secret to its key. Valid keys are cached
+// in Redis for a few minutes; Revoke clears the cache entry.
+func Authenticate(db *sql.DB, secret string) (Key, error) {
+	if !strings.HasPrefix(secret, Prefix) {
+		return Key{}, ErrInvalidKey
+	}
+	hash := security.HashToken(secret)
+
+	var entry cacheEntry
+	cached, err := storage.RedisClient.Get(storage.Ctx, cacheKeyPrefix+hash).Bytes()
+	if err == nil && json.Unmarshal(cached, &entry) == nil {
+		touch(db, entry.ID)
+		return Key{ID: entry.ID, UserID: entry.UserID, Scopes: entry.Scopes}, nil
+	}
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return Key{}, err
+	}
+
+	var k Key
+	err = db.QueryRow(`
+		SELECT id, user_id, name, prefix, scopes, created_at
+		FROM api_keys
+		WHERE key_hash = $1 AND revoked_at IS NULL`, hash,
+	).Scan(&k.ID, &k.UserID, &k.Name, &k.Prefix, pq.Array(&k.Scopes), &k.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Key{}, ErrInvalidKey
+	}
+	if err != nil {
+		return Key{}, err
+	}
+
+	if b, err := json.Marshal(cacheEntry{ID: k.ID, UserID: k.UserID, Scopes: k.Scopes}); err == nil {
+		storage.RedisClient.Set(storage.Ctx, cacheKeyPrefix+hash, b, cacheTTL)
+	}
+	touch(db, k.ID)
+	return k, nil
+}
+
+// cacheEntry is the Redis form of an authenticated key.
+type cacheEntry struct {
+	ID     string   `json:"id"`
+	UserID string   `json:"user_id"`
+	Scopes []string `json:"scopes"`
+}
+
+// touch records key use, at most once per lastUsedInterval.
+func touch(db *sql.DB, id string) {
+	first, err := storage.RedisClient.SetNX(storage.Ctx, usedKeyPrefix+id, 1, lastUsedInterval).Result()
+	if err != nil || !first {
+		return
+	}
+	_, _ = db.Exec(`UPDATE api_keys SET last_used_at = NOW() WHERE id = $1`, id)
+}
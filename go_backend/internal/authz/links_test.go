@@ -0,0 +1,69 @@
+package authz
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+const (
+	testLinkID = "link-a"
+	testUserA  = "user-a"
+	testUserB  = "user-b"
+)
+
+func TestOwnedLink(t *testing.T) {
+	tests := []struct {
+		name    string
+		userID  string
+		owned   bool
+		wantErr error
+	}{
+		{name: "owner", userID: testUserA, owned: true},
+		{name: "other user", userID: testUserB, wantErr: ErrLinkNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+
+			rows := sqlmock.NewRows([]string{"id", "user_id", "slug"})
+			if tt.owned {
+				rows.AddRow(testLinkID, testUserA, "a-slug")
+			}
+			mock.ExpectQuery(`SELECT id, user_id, slug FROM urls WHERE id = \$1 AND user_id = \$2`).
+				WithArgs(testLinkID, tt.userID).
+				WillReturnRows(rows)
+
+			link, err := OwnedLink(db, testLinkID, tt.userID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("OwnedLink() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && (link.ID != testLinkID || link.UserID != testUserA) {
+				t.Errorf("OwnedLink() = %+v, want link %s of %s", link, testLinkID, testUserA)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestOwnedLinkWithoutUser(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err := OwnedLink(db, testLinkID, ""); !errors.Is(err, ErrLinkNotFound) {
+		t.Fatalf("OwnedLink() error = %v, want %v", err, ErrLinkNotFound)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
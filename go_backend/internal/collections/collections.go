@@ -0,0 +1,57 @@
+// Package collections manages the tags and folders users organise their
+// links with.
+//
+// A link can carry any number of tags (url_tags) and sit in at most one
+// folder (urls.folder_id). Names are unique per user, ignoring case.
+package collections
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/lib/pq"
+)
+
+// MaxNameLength is the longest tag or folder name, in characters.
+const MaxNameLength = 50
+
+// MaxTagsPerLink caps the tags a single link may carry.
+const MaxTagsPerLink = 20
+
+var (
+	// ErrNotFound is returned for unknown tags and folders and for those of
+	// other users.
+	ErrNotFound = errors.New("not found")
+	// ErrInvalidName is returned for empty or overlong names.
+	ErrInvalidName = errors.New("name must be 1-50 characters")
+	// ErrNameTaken is returned when the user already has a tag or folder
+	// with the same name.
+	ErrNameTaken = errors.New("name already in use")
+	// ErrTooManyTags is returned when a link would carry more than
+	// MaxTagsPerLink tags.
+	ErrTooManyTags = errors.New("at most 20 tags per link")
+)
+
+// Querier is implemented by both *sql.DB and *sql.Tx.
+type Querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// NormalizeName trims name and checks its length.
+func NormalizeName(name string) (string, error) {
+	name = strings.Join(strings.Fields(name), " ")
+	if name == "" || utf8.RuneCountInString(name) > MaxNameLength {
+		return "", ErrInvalidName
+	}
+	return name, nil
+}
+
+// isUniqueViolation reports whether err is a Postgres unique violation.
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
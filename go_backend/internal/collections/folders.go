@@ -0,0 +1,117 @@
+package collections
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Folder is a user's folder with the number of links in it.
+type Folder struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Links     int       `json:"links"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ListFolders returns the user's folders ordered by name.
+func ListFolders(db *sql.DB, userID string) ([]Folder, error) {
+	rows, err := db.Query(`
+		SELECT f.id, f.name, f.created_at, COUNT(u.id)
+		FROM folders f
+		LEFT JOIN urls u ON u.folder_id = f.id
+		WHERE f.user_id = $1
+		GROUP BY f.id
+		ORDER BY lower(f.name)`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	folders := []Folder{}
+	for rows.Next() {
+		var f Folder
+		if err := rows.Scan(&f.ID, &f.Name, &f.CreatedAt, &f.Links); err != nil {
+			return nil, err
+		}
+		folders = append(folders, f)
+	}
+	return folders, rows.Err()
+}
+
+// CreateFolder adds a folder. name must already be normalized.
+func CreateFolder(db *sql.DB, userID, name string) (Folder, error) {
+	f := Folder{ID: uuid.NewString(), Name: name}
+	err := db.QueryRow(`
+		INSERT INTO folders (id, user_id, name) VALUES ($1, $2, $3)
+		RETURNING created_at`, f.ID, userID, name).Scan(&f.CreatedAt)
+	if isUniqueViolation(err) {
+		return Folder{}, ErrNameTaken
+	}
+	return f, err
+}
+
+// RenameFolder changes the name of one of the user's folders.
+func RenameFolder(db *sql.DB, userID, id, name string) (Folder, error) {
+	f := Folder{ID: id, Name: name}
+	err := db.QueryRow(`
+		UPDATE folders SET name = $3 WHERE id = $1 AND user_id = $2
+		RETURNING created_at, (SELECT COUNT(*) FROM urls WHERE folder_id = $1)`,
+		id, userID, name).Scan(&f.CreatedAt, &f.Links)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return Folder{}, ErrNotFound
+	case isUniqueViolation(err):
+		return Folder{}, ErrNameTaken
+	}
+	return f, err
+}
+
+// DeleteFolder deletes one of the user's folders. Its links are kept and
+// no longer belong to a folder.
+func DeleteFolder(db *sql.DB, userID, id string) error {
+	res, err := db.Exec(`DELETE FROM folders WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// OwnedFolder returns the folder with id if it belongs to userID, or
+// ErrNotFound.
+func OwnedFolder(q Querier, userID, id string) (Folder, error) {
+	f := Folder{ID: id}
+	err := q.QueryRow(`SELECT name, created_at FROM folders WHERE id = $1 AND user_id = $2`, id, userID).
+		Scan(&f.Name, &f.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return f, ErrNotFound
+	}
+	return f, err
+}
+
+// OwnedFolders returns which of ids are folders of userID.
+func OwnedFolders(q Querier, userID string, ids []string) (map[string]bool, error) {
+	owned := make(map[string]bool)
+	if len(ids) == 0 {
+		return owned, nil
+	}
+	rows, err := q.Query(`SELECT id FROM folders WHERE user_id = $1 AND id = ANY($2)`, userID, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		owned[id] = true
+	}
+	return owned, rows.Err()
+}
@@ -0,0 +1,234 @@
+package collections
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Tag is a user's tag with the number of links carrying it.
+type Tag struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Links     int       `json:"links"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TagRef identifies a tag on a link.
+type TagRef struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// NormalizeTagNames normalizes names, drops duplicates that differ only in
+// case and enforces MaxTagsPerLink.
+func NormalizeTagNames(names []string) ([]string, error) {
+	out := make([]string, 0, len(names))
+	seen := make(map[string]bool, len(names))
+	for _, n := range names {
+		n, err := NormalizeName(n)
+		if err != nil {
+			return nil, err
+		}
+		if key := strings.ToLower(n); !seen[key] {
+			seen[key] = true
+			out = append(out, n)
+		}
+	}
+	if len(out) > MaxTagsPerLink {
+		return nil, ErrTooManyTags
+	}
+	return out, nil
+}
+
+// ListTags returns the user's tags ordered by name.
+func ListTags(db *sql.DB, userID string) ([]Tag, error) {
+	rows, err := db.Query(`
+		SELECT t.id, t.name, t.created_at, COUNT(ut.url_id)
+		FROM tags t
+		LEFT JOIN url_tags ut ON ut.tag_id = t.id
+		WHERE t.user_id = $1
+		GROUP BY t.id
+		ORDER BY lower(t.name)`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []Tag{}
+	for rows.Next() {
+		var t Tag
+		if err := rows.Scan(&t.ID, &t.Name, &t.CreatedAt, &t.Links); err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+	return tags, rows.Err()
+}
+
+// CreateTag adds a tag. name must already be normalized.
+func CreateTag(db *sql.DB, userID, name string) (Tag, error) {
+	t := Tag{ID: uuid.NewString(), Name: name}
+	err := db.QueryRow(`
+		INSERT INTO tags (id, user_id, name) VALUES ($1, $2, $3)
+		RETURNING created_at`, t.ID, userID, name).Scan(&t.CreatedAt)
+	if isUniqueViolation(err) {
+		return Tag{}, ErrNameTaken
+	}
+	return t, err
+}
+
+// RenameTag changes the name of one of the user's tags.
+func RenameTag(db *sql.DB, userID, id, name string) (Tag, error) {
+	t := Tag{ID: id, Name: name}
+	err := db.QueryRow(`
+		UPDATE tags SET name = $3 WHERE id = $1 AND user_id = $2
+		RETURNING created_at, (SELECT COUNT(*) FROM url_tags WHERE tag_id = $1)`,
+		id, userID, name).Scan(&t.CreatedAt, &t.Links)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return Tag{}, ErrNotFound
+	case isUniqueViolation(err):
+		return Tag{}, ErrNameTaken
+	}
+	return t, err
+}
+
+// DeleteTag removes one of the user's tags from all links and deletes it.
+func DeleteTag(db *sql.DB, userID, id string) error {
+	res, err := db.Exec(`DELETE FROM tags WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// OwnedTag returns the tag with id if it belongs to userID, or ErrNotFound.
+func OwnedTag(q Querier, userID, id string) (TagRef, error) {
+	var t TagRef
+	err := q.QueryRow(`SELECT id, name FROM tags WHERE id = $1 AND user_id = $2`, id, userID).Scan(&t.ID, &t.Name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return t, ErrNotFound
+	}
+	return t, err
+}
+
+// EnsureTags creates the named tags the user does not have yet and returns
+// the IDs of all of them, keyed by lower-cased name. names must already be
+// normalized.
+func EnsureTags(q Querier, userID string, names []string) (map[string]string, error) {
+	ids := make(map[string]string, len(names))
+	if len(names) == 0 {
+		return ids, nil
+	}
+
+	newIDs := make([]string, len(names))
+	lower := make([]string, len(names))
+	for i, n := range names {
+		newIDs[i] = uuid.NewString()
+		lower[i] = strings.ToLower(n)
+	}
+	_, err := q.Exec(`
+		INSERT INTO tags (id, user_id, name)
+		SELECT unnest($1::text[]), $2, unnest($3::text[])
+		ON CONFLICT (user_id, lower(name)) DO NOTHING`,
+		pq.Array(newIDs), userID, pq.Array(names))
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := q.Query(`
+		SELECT id, lower(name) FROM tags WHERE user_id = $1 AND lower(name) = ANY($2)`,
+		userID, pq.Array(lower))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, err
+		}
+		ids[name] = id
+	}
+	return ids, rows.Err()
+}
+
+// SetLinkTags replaces the tags of a link with the named ones, creating
+// missing tags. names must already be normalized.
+func SetLinkTags(q Querier, userID, linkID string, names []string) error {
+	if _, err := q.Exec(`DELETE FROM url_tags WHERE url_id = $1`, linkID); err != nil {
+		return err
+	}
+	links := make([]string, len(names))
+	for i := range names {
+		links[i] = linkID
+	}
+	return AddLinkTags(q, userID, links, names)
+}
+
+// AddLinkTags tags links[i] with names[i], creating missing tags. Pairs
+// that already exist are skipped. names must already be normalized.
+func AddLinkTags(q Querier, userID string, links, names []string) error {
+	if len(links) == 0 {
+		return nil
+	}
+	unique := make([]string, 0, len(names))
+	seen := make(map[string]bool, len(names))
+	for _, n := range names {
+		if key := strings.ToLower(n); !seen[key] {
+			seen[key] = true
+			unique = append(unique, n)
+		}
+	}
+	ids, err := EnsureTags(q, userID, unique)
+	if err != nil {
+		return err
+	}
+
+	tagIDs := make([]string, len(names))
+	for i, n := range names {
+		tagIDs[i] = ids[strings.ToLower(n)]
+	}
+	_, err = q.Exec(`
+		INSERT INTO url_tags (url_id, tag_id)
+		SELECT unnest($1::text[]), unnest($2::text[])
+		ON CONFLICT DO NOTHING`,
+		pq.Array(links), pq.Array(tagIDs))
+	return err
+}
+
+// LinkTags returns the tags of each of linkIDs, ordered by name. Links
+// without tags are absent from the map.
+func LinkTags(q Querier, linkIDs []string) (map[string][]TagRef, error) {
+	tags := make(map[string][]TagRef)
+	if len(linkIDs) == 0 {
+		return tags, nil
+	}
+	rows, err := q.Query(`
+		SELECT ut.url_id, t.id, t.name
+		FROM url_tags ut
+		JOIN tags t ON t.id = ut.tag_id
+		WHERE ut.url_id = ANY($1)
+		ORDER BY lower(t.name)`, pq.Array(linkIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var linkID string
+		var t TagRef
+		if err := rows.Scan(&linkID, &t.ID, &t.Name); err != nil {
+			return nil, err
+		}
+		tags[linkID] = append(tags[linkID], t)
+	}
+	return tags, rows.Err()
+}
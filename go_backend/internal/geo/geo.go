@@ -0,0 +1,88 @@
+// Package geo resolves client IP addresses to locations through a pluggable
+// Resolver.
+//
+// The server installs a resolver once at startup with SetDefault (usually
+// FromEnv); the redirect handler and the analytics pipeline look up through
+// Default. Without a database every lookup returns an empty Location.
+package geo
+
+import (
+	"log"
+	"net"
+	"os"
+	"sync"
+)
+
+// Location is where an IP address is registered. Fields are empty when
+// unknown; Country is an ISO 3166-1 alpha-2 code such as "US".
+type Location struct {
+	Country string
+	Region  string
+	City    string
+}
+
+// Resolver looks up the location of an IP address.
+type Resolver interface {
+	Lookup(ip net.IP) (Location, error)
+}
+
+var (
+	mu      sync.RWMutex
+	current Resolver = Nop{}
+)
+
+// Default returns the installed Resolver. Until SetDefault is called it is
+// Nop.
+func Default() Resolver {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+// SetDefault installs r as the Resolver returned by Default.
+func SetDefault(r Resolver) {
+	mu.Lock()
+	defer mu.Unlock()
+	current = r
+}
+
+// FromEnv opens the MaxMind-format database at GEOIP_DB_PATH, such as
+// GeoLite2-City.mmdb or GeoLite2-Country.mmdb. It returns Nop when the
+// variable is unset or the file cannot be read, so geo features degrade
+// instead of stopping the server.
+func FromEnv() Resolver {
+	path := os.Getenv("GEOIP_DB_PATH")
+	if path == "" {
+		log.Println("geo: GEOIP_DB_PATH not set, visitor locations will be unknown")
+		return Nop{}
+	}
+	db, err := OpenMMDB(path)
+	if err != nil {
+		log.Printf("geo: could not open %s: %v", path, err)
+		return Nop{}
+	}
+	log.Printf("geo: loaded %s (%s, built %s)", path, db.DatabaseType(), db.BuildTime().Format("2006-01-02"))
+	return db
+}
+
+// LookupString parses ip and looks it up with Default. Unparsable addresses
+// and lookup errors yield an empty Location.
+func LookupString(ip string) Location {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return Location{}
+	}
+	loc, err := Default().Lookup(parsed)
+	if err != nil {
+		return Location{}
+	}
+	return loc
+}
+
+// Nop resolves every address to an empty Location.
+type Nop struct{}
+
+// Lookup returns an empty Location.
+func (Nop) Lookup(net.IP) (Location, error) {
+	return Location{}, nil
+}
@@ -0,0 +1,406 @@
+package geo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"net"
+	"os"
+	"time"
+)
+
+// metadataMarker precedes the metadata map at the end of an MMDB file.
+var metadataMarker = []byte("\xab\xcd\xefMaxMind.com")
+
+// dataSectionSeparator is the number of zero bytes between the search tree
+// and the data section.
+const dataSectionSeparator = 16
+
+// Data section field types.
+const (
+	typeExtended = iota
+	typePointer
+	typeString
+	typeDouble
+	typeBytes
+	typeUint16
+	typeUint32
+	typeMap
+	typeInt32
+	typeUint64
+	typeUint128
+	typeArray
+	typeContainer
+	typeEndMarker
+	typeBool
+	typeFloat
+)
+
+// maxDecodeDepth bounds nesting so that a corrupt file cannot recurse
+// without limit.
+const maxDecodeDepth = 32
+
+// ErrInvalidMMDB is returned for files that are not valid MMDB databases.
+var ErrInvalidMMDB = errors.New("invalid MaxMind database")
+
+// MMDB is an in-memory MaxMind DB (https://maxmind.github.io/MaxMind-DB/)
+// reader. It is safe for concurrent use.
+type MMDB struct {
+	buf        []byte
+	tree       []byte
+	data       []byte
+	nodeCount  uint
+	recordSize uint
+	ipVersion  uint
+	ipv4Start  uint
+	dbType     string
+	buildEpoch uint64
+}
+
+// OpenMMDB reads the database at path into memory.
+func OpenMMDB(path string) (*MMDB, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewMMDB(buf)
+}
+
+// NewMMDB parses a database held in buf.
+func NewMMDB(buf []byte) (*MMDB, error) {
+	start := bytes.LastIndex(buf, metadataMarker)
+	if start < 0 {
+		return nil, ErrInvalidMMDB
+	}
+	meta := decoder{data: buf[start+len(metadataMarker):]}
+	v, _, err := meta.decode(0, 0)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, ErrInvalidMMDB
+	}
+
+	db := &MMDB{
+		buf:        buf,
+		nodeCount:  uint(toUint(m["node_count"])),
+		recordSize: uint(toUint(m["record_size"])),
+		ipVersion:  uint(toUint(m["ip_version"])),
+		buildEpoch: toUint(m["build_epoch"]),
+	}
+	db.dbType, _ = m["database_type"].(string)
+	switch db.recordSize {
+	case 24, 28, 32:
+	default:
+		return nil, fmt.Errorf("%w: record size %d", ErrInvalidMMDB, db.recordSize)
+	}
+
+	// Checking the node count first keeps the tree size from overflowing.
+	if db.nodeCount > uint(start) {
+		return nil, ErrInvalidMMDB
+	}
+	treeSize := db.nodeCount * db.recordSize / 4
+	if treeSize+dataSectionSeparator > uint(start) {
+		return nil, ErrInvalidMMDB
+	}
+	db.tree = buf[:treeSize]
+	db.data = buf[treeSize+dataSectionSeparator : start]
+
+	// IPv4 addresses live under ::/96 in IPv6 databases.
+	if db.ipVersion == 6 {
+		node := uint(0)
+		for i := 0; i < 96 && node < db.nodeCount; i++ {
+			node = db.record(node, 0)
+		}
+		db.ipv4Start = node
+	}
+	return db, nil
+}
+
+// DatabaseType returns the database_type metadata, e.g. "GeoLite2-City".
+func (db *MMDB) DatabaseType() string {
+	return db.dbType
+}
+
+// BuildTime returns when the database was built.
+func (db *MMDB) BuildTime() time.Time {
+	return time.Unix(int64(db.buildEpoch), 0).UTC()
+}
+
+// Lookup returns the country, first subdivision and city of ip. Addresses
+// missing from the database yield an empty Location.
+func (db *MMDB) Lookup(ip net.IP) (Location, error) {
+	v, err := db.LookupRecord(ip)
+	if err != nil || v == nil {
+		return Location{}, err
+	}
+	rec, _ := v.(map[string]interface{})
+
+	var loc Location
+	country, ok := rec["country"].(map[string]interface{})
+	if !ok {
+		country, _ = rec["registered_country"].(map[string]interface{})
+	}
+	loc.Country, _ = country["iso_code"].(string)
+	if subs, ok := rec["subdivisions"].([]interface{}); ok && len(subs) > 0 {
+		sub, _ := subs[0].(map[string]interface{})
+		if loc.Region = englishName(sub); loc.Region == "" {
+			loc.Region, _ = sub["iso_code"].(string)
+		}
+	}
+	city, _ := rec["city"].(map[string]interface{})
+	loc.City = englishName(city)
+	return loc, nil
+}
+
+// LookupRecord returns the decoded data record for ip, or nil if the
+// database has no record for it.
+func (db *MMDB) LookupRecord(ip net.IP) (interface{}, error) {
+	node, bits := uint(0), 128
+	if ip4 := ip.To4(); ip4 != nil {
+		ip, bits = ip4, 32
+		if db.ipVersion == 6 {
+			node = db.ipv4Start
+		}
+	} else if db.ipVersion == 4 {
+		return nil, nil
+	}
+
+	for i := 0; i < bits && node < db.nodeCount; i++ {
+		bit := uint(ip[i>>3]>>(7-uint(i&7))) & 1
+		node = db.record(node, bit)
+	}
+	if node <= db.nodeCount {
+		return nil, nil
+	}
+
+	offset := node - db.nodeCount - dataSectionSeparator
+	if offset >= uint(len(db.data)) {
+		return nil, ErrInvalidMMDB
+	}
+	d := decoder{data: db.data}
+	v, _, err := d.decode(offset, 0)
+	return v, err
+}
+
+// record returns the left (bit 0) or right (bit 1) record of node.
+func (db *MMDB) record(node, bit uint) uint {
+	switch db.recordSize {
+	case 24:
+		off := node*6 + bit*3
+		b := db.tree[off : off+3]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+	case 28:
+		b := db.tree[node*7 : node*7+7]
+		if bit == 0 {
+			return uint(b[3]&0xf0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3]&0x0f)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	default:
+		off := node*8 + bit*4
+		return uint(binary.BigEndian.Uint32(db.tree[off : off+4]))
+	}
+}
+
+// englishName returns names.en of a record, if present.
+func englishName(rec map[string]interface{}) string {
+	names, _ := rec["names"].(map[string]interface{})
+	name, _ := names["en"].(string)
+	return name
+}
+
+// toUint converts a decoded unsigned integer to uint64.
+func toUint(v interface{}) uint64 {
+	switch n := v.(type) {
+	case uint64:
+		return n
+	case uint32:
+		return uint64(n)
+	case uint16:
+		return uint64(n)
+	}
+	return 0
+}
+
+// decoder decodes values of the MMDB data section format.
+type decoder struct {
+	data []byte
+}
+
+// decode returns the value at offset and the offset following it.
+func (d decoder) decode(offset uint, depth int) (interface{}, uint, error) {
+	if depth > maxDecodeDepth {
+		return nil, 0, ErrInvalidMMDB
+	}
+	typ, size, offset, err := d.control(offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if typ == typePointer {
+		target, next, err := d.pointer(size, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		v, _, err := d.decode(target, depth+1)
+		return v, next, err
+	}
+
+	// Every entry takes at least one byte, which bounds the allocations
+	// below for corrupt sizes.
+	if (typ == typeMap || typ == typeArray) && size > uint(len(d.data))-offset {
+		return nil, 0, ErrInvalidMMDB
+	}
+
+	switch typ {
+	case typeMap:
+		m := make(map[string]interface{}, size)
+		for i := uint(0); i < size; i++ {
+			k, next, err := d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				return nil, 0, ErrInvalidMMDB
+			}
+			v, next, err := d.decode(next, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			m[key] = v
+			offset = next
+		}
+		return m, offset, nil
+	case typeArray:
+		a := make([]interface{}, 0, size)
+		for i := uint(0); i < size; i++ {
+			v, next, err := d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			a = append(a, v)
+			offset = next
+		}
+		return a, offset, nil
+	case typeBool:
+		return size != 0, offset, nil
+	case typeEndMarker, typeContainer:
+		return nil, offset, nil
+	}
+
+	if offset+size > uint(len(d.data)) {
+		return nil, 0, ErrInvalidMMDB
+	}
+	b, next := d.data[offset:offset+size], offset+size
+	switch typ {
+	case typeString:
+		return string(b), next, nil
+	case typeBytes:
+		return append([]byte(nil), b...), next, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, ErrInvalidMMDB
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), next, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, ErrInvalidMMDB
+		}
+		return math.Float32frombits(binary.BigEndian.Uint32(b)), next, nil
+	case typeUint16, typeUint32, typeUint64:
+		if size > 8 {
+			return nil, 0, ErrInvalidMMDB
+		}
+		var n uint64
+		for _, c := range b {
+			n = n<<8 | uint64(c)
+		}
+		return n, next, nil
+	case typeInt32:
+		if size > 4 {
+			return nil, 0, ErrInvalidMMDB
+		}
+		var n uint32
+		for _, c := range b {
+			n = n<<8 | uint32(c)
+		}
+		return int32(n), next, nil
+	case typeUint128:
+		if size > 16 {
+			return nil, 0, ErrInvalidMMDB
+		}
+		return new(big.Int).SetBytes(b), next, nil
+	}
+	return nil, 0, fmt.Errorf("%w: unknown type %d", ErrInvalidMMDB, typ)
+}
+
+// control reads the control byte(s) at offset and returns the field type,
+// its size (or, for pointers, the size bits) and the payload offset.
+func (d decoder) control(offset uint) (typ int, size uint, next uint, err error) {
+	if offset >= uint(len(d.data)) {
+		return 0, 0, 0, ErrInvalidMMDB
+	}
+	ctrl := d.data[offset]
+	offset++
+	typ = int(ctrl >> 5)
+	if typ == typePointer {
+		return typ, uint(ctrl & 0x1f), offset, nil
+	}
+	if typ == typeExtended {
+		if offset >= uint(len(d.data)) {
+			return 0, 0, 0, ErrInvalidMMDB
+		}
+		typ = 7 + int(d.data[offset])
+		offset++
+	}
+
+	size = uint(ctrl & 0x1f)
+	if size >= 29 {
+		n := size - 28
+		if offset+n > uint(len(d.data)) {
+			return 0, 0, 0, ErrInvalidMMDB
+		}
+		var extra uint
+		for _, c := range d.data[offset : offset+n] {
+			extra = extra<<8 | uint(c)
+		}
+		offset += n
+		switch n {
+		case 1:
+			size = 29 + extra
+		case 2:
+			size = 285 + extra
+		default:
+			size = 65821 + extra
+		}
+	}
+	return typ, size, offset, nil
+}
+
+// pointer resolves a pointer whose control byte carried bits, returning
+// the target offset and the offset following the pointer.
+func (d decoder) pointer(bits, offset uint) (uint, uint, error) {
+	n := (bits>>3)&0x3 + 1
+	if offset+n > uint(len(d.data)) {
+		return 0, 0, ErrInvalidMMDB
+	}
+	var p uint
+	if n < 4 {
+		p = bits & 0x7
+	}
+	for _, c := range d.data[offset : offset+n] {
+		p = p<<8 | uint(c)
+	}
+	switch n {
+	case 2:
+		p += 2048
+	case 3:
+		p += 526336
+	}
+	return p, offset + n, nil
+}
@@ -0,0 +1,441 @@
+package geo
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mmdbWriter encodes values in the MMDB data section format.
+type mmdbWriter struct {
+	buf []byte
+}
+
+// mmdbPointer is written as a pointer to the given data section offset.
+type mmdbPointer uint
+
+// ctrl writes the control byte, extended type and size bytes of a field.
+func (w *mmdbWriter) ctrl(typ, size int) {
+	var b byte
+	var ext []byte
+	if typ > 7 {
+		ext = []byte{byte(typ - 7)}
+	} else {
+		b = byte(typ) << 5
+	}
+	switch {
+	case size < 29:
+		b |= byte(size)
+	case size < 285:
+		b |= 29
+		ext = append(ext, byte(size-29))
+	case size < 65821:
+		b |= 30
+		s := size - 285
+		ext = append(ext, byte(s>>8), byte(s))
+	default:
+		b |= 31
+		s := size - 65821
+		ext = append(ext, byte(s>>16), byte(s>>8), byte(s))
+	}
+	w.buf = append(append(w.buf, b), ext...)
+}
+
+// write encodes v and returns its offset.
+func (w *mmdbWriter) write(v interface{}) uint {
+	offset := uint(len(w.buf))
+	switch v := v.(type) {
+	case string:
+		w.ctrl(typeString, len(v))
+		w.buf = append(w.buf, v...)
+	case bool:
+		size := 0
+		if v {
+			size = 1
+		}
+		w.ctrl(typeBool, size)
+	case uint16:
+		w.ctrl(typeUint16, 2)
+		w.buf = binary.BigEndian.AppendUint16(w.buf, v)
+	case uint32:
+		w.ctrl(typeUint32, 4)
+		w.buf = binary.BigEndian.AppendUint32(w.buf, v)
+	case uint64:
+		w.ctrl(typeUint64, 8)
+		w.buf = binary.BigEndian.AppendUint64(w.buf, v)
+	case []interface{}:
+		w.ctrl(typeArray, len(v))
+		for _, e := range v {
+			w.write(e)
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		w.ctrl(typeMap, len(v))
+		for _, k := range keys {
+			w.write(k)
+			w.write(v[k])
+		}
+	case mmdbPointer:
+		p := uint(v)
+		switch {
+		case p < 2048:
+			w.buf = append(w.buf, 0x20|byte(p>>8), byte(p))
+		case p < 526336:
+			p -= 2048
+			w.buf = append(w.buf, 0x28|byte(p>>16), byte(p>>8), byte(p))
+		default:
+			w.buf = append(w.buf, 0x38)
+			w.buf = binary.BigEndian.AppendUint32(w.buf, uint32(p))
+		}
+	default:
+		panic("mmdbWriter: unsupported type")
+	}
+	return offset
+}
+
+// testNetwork maps a CIDR to a data section offset.
+type testNetwork struct {
+	cidr string
+	data uint
+}
+
+// buildMMDB assembles a database with the given record size and IP version
+// whose search tree maps networks into data.
+func buildMMDB(t *testing.T, recordSize, ipVersion int, networks []testNetwork, data []byte) []byte {
+	t.Helper()
+
+	// Records are node indexes, emptyRecord, or -2-offset for data.
+	const emptyRecord = -1
+	nodes := [][2]int{{emptyRecord, emptyRecord}}
+	for _, n := range networks {
+		_, ipnet, err := net.ParseCIDR(n.cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ip := ipnet.IP
+		ones, _ := ipnet.Mask.Size()
+		if ip4 := ip.To4(); ip4 != nil {
+			ip = ip4
+			if ipVersion == 6 {
+				ip, ones = append(make(net.IP, 12), ip4...), ones+96
+			}
+		}
+		node := 0
+		for i := 0; i < ones; i++ {
+			bit := int(ip[i>>3]>>(7-uint(i&7))) & 1
+			if i == ones-1 {
+				nodes[node][bit] = -2 - int(n.data)
+				break
+			}
+			if nodes[node][bit] == emptyRecord {
+				nodes = append(nodes, [2]int{emptyRecord, emptyRecord})
+				nodes[node][bit] = len(nodes) - 1
+			}
+			node = nodes[node][bit]
+		}
+	}
+
+	nodeCount := uint32(len(nodes))
+	value := func(r int) uint32 {
+		switch {
+		case r == emptyRecord:
+			return nodeCount
+		case r < 0:
+			return nodeCount + dataSectionSeparator + uint32(-2-r)
+		}
+		return uint32(r)
+	}
+	var buf []byte
+	for _, n := range nodes {
+		l, r := value(n[0]), value(n[1])
+		switch recordSize {
+		case 24:
+			buf = append(buf, byte(l>>16), byte(l>>8), byte(l), byte(r>>16), byte(r>>8), byte(r))
+		case 28:
+			buf = append(buf, byte(l>>16), byte(l>>8), byte(l), byte(l>>24)<<4|byte(r>>24), byte(r>>16), byte(r>>8), byte(r))
+		case 32:
+			buf = binary.BigEndian.AppendUint32(buf, l)
+			buf = binary.BigEndian.AppendUint32(buf, r)
+		}
+	}
+	buf = append(buf, make([]byte, dataSectionSeparator)...)
+	buf = append(buf, data...)
+	buf = append(buf, metadataMarker...)
+
+	var meta mmdbWriter
+	meta.write(map[string]interface{}{
+		"node_count":    nodeCount,
+		"record_size":   uint16(recordSize),
+		"ip_version":    uint16(ipVersion),
+		"database_type": "Test-City",
+		"build_epoch":   uint64(1700000000),
+	})
+	return append(buf, meta.buf...)
+}
+
+// longNote has a length that needs three extended size bytes.
+var longNote = strings.Repeat("n", 70000)
+
+// testData returns a data section with three records and the networks that
+// point at them. The Munich record reaches Germany through a pointer.
+func testData() ([]byte, []testNetwork) {
+	var w mmdbWriter
+	berlin := w.write(map[string]interface{}{
+		"country": map[string]interface{}{"iso_code": "DE"},
+		"subdivisions": []interface{}{
+			map[string]interface{}{"iso_code": "BE", "names": map[string]interface{}{"en": "Land Berlin"}},
+		},
+		"city":                 map[string]interface{}{"names": map[string]interface{}{"en": "Berlin"}},
+		"is_in_european_union": true,
+	})
+	germany := findCountry(w.buf, berlin)
+	munich := w.write(map[string]interface{}{
+		"country":      mmdbPointer(germany),
+		"subdivisions": []interface{}{map[string]interface{}{"iso_code": "BY"}},
+		"city":         map[string]interface{}{"names": map[string]interface{}{"en": "Munich"}},
+	})
+	amsterdam := w.write(map[string]interface{}{
+		"registered_country": map[string]interface{}{"iso_code": "NL"},
+		"subdivisions": []interface{}{
+			map[string]interface{}{"names": map[string]interface{}{"en": strings.Repeat("r", 40)}},
+		},
+		"city": map[string]interface{}{"names": map[string]interface{}{"en": strings.Repeat("c", 300)}},
+		"note": longNote,
+	})
+	return w.buf, []testNetwork{
+		{cidr: "81.2.69.0/24", data: berlin},
+		{cidr: "2001:db8::/32", data: munich},
+		{cidr: "10.0.0.0/8", data: amsterdam},
+	}
+}
+
+// findCountry returns the offset of the "country" value of the map at
+// offset.
+func findCountry(data []byte, offset uint) uint {
+	d := decoder{data: data}
+	_, size, next, err := d.control(offset)
+	if err != nil {
+		panic(err)
+	}
+	for i := uint(0); i < size; i++ {
+		k, valueAt, err := d.decode(next, 0)
+		if err != nil {
+			panic(err)
+		}
+		if k == "country" {
+			return valueAt
+		}
+		if _, next, err = d.decode(valueAt, 0); err != nil {
+			panic(err)
+		}
+	}
+	panic("no country")
+}
+
+func TestMMDBLookup(t *testing.T) {
+	data, networks := testData()
+	tests := []struct {
+		ip   string
+		want Location
+	}{
+		{ip: "81.2.69.142", want: Location{Country: "DE", Region: "Land Berlin", City: "Berlin"}},
+		{ip: "::ffff:81.2.69.142", want: Location{Country: "DE", Region: "Land Berlin", City: "Berlin"}},
+		{ip: "10.1.2.3", want: Location{Country: "NL", Region: strings.Repeat("r", 40), City: strings.Repeat("c", 300)}},
+		{ip: "2001:db8::1", want: Location{Country: "DE", Region: "BY", City: "Munich"}},
+		{ip: "81.2.70.1"},
+		{ip: "2002::1"},
+	}
+
+	for _, recordSize := range []int{24, 28, 32} {
+		buf := buildMMDB(t, recordSize, 6, networks, data)
+		db, err := NewMMDB(buf)
+		if err != nil {
+			t.Fatalf("record size %d: NewMMDB() error = %v", recordSize, err)
+		}
+		if db.DatabaseType() != "Test-City" || !db.BuildTime().Equal(time.Unix(1700000000, 0)) {
+			t.Errorf("record size %d: metadata = %q, %v", recordSize, db.DatabaseType(), db.BuildTime())
+		}
+		for _, tt := range tests {
+			got, err := db.Lookup(net.ParseIP(tt.ip))
+			if err != nil || got != tt.want {
+				t.Errorf("record size %d: Lookup(%s) = %+v, %v, want %+v", recordSize, tt.ip, got, err, tt.want)
+			}
+		}
+
+		rec, err := db.LookupRecord(net.ParseIP("10.1.2.3"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if note, _ := rec.(map[string]interface{})["note"].(string); note != longNote {
+			t.Errorf("record size %d: note has %d bytes, want %d", recordSize, len(note), len(longNote))
+		}
+		rec, err = db.LookupRecord(net.ParseIP("81.2.69.142"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if eu, _ := rec.(map[string]interface{})["is_in_european_union"].(bool); !eu {
+			t.Errorf("record size %d: is_in_european_union = %v, want true", recordSize, eu)
+		}
+	}
+}
+
+func TestMMDBLookupIPv4Database(t *testing.T) {
+	data, networks := testData()
+	db, err := NewMMDB(buildMMDB(t, 24, 4, networks[:1], data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := db.Lookup(net.ParseIP("81.2.69.1")); err != nil || got.City != "Berlin" {
+		t.Errorf("Lookup(81.2.69.1) = %+v, %v, want Berlin", got, err)
+	}
+	if got, err := db.Lookup(net.ParseIP("2001:db8::1")); err != nil || got != (Location{}) {
+		t.Errorf("Lookup(2001:db8::1) = %+v, %v, want empty", got, err)
+	}
+}
+
+func TestMMDBRecord(t *testing.T) {
+	tests := []struct {
+		recordSize  uint
+		tree        []byte
+		left, right uint
+	}{
+		{24, []byte{0x01, 0x02, 0x03, 0xa1, 0xb2, 0xc3}, 0x010203, 0xa1b2c3},
+		// The middle byte holds the top nibble of both 28-bit records.
+		{28, []byte{0x1b, 0x2c, 0x3d, 0xa5, 0xe6, 0xf7, 0x08}, 0x0a1b2c3d, 0x05e6f708},
+		{32, []byte{0xf1, 0x02, 0x03, 0x04, 0x0a, 0x0b, 0x0c, 0x0d}, 0xf1020304, 0x0a0b0c0d},
+	}
+	for _, tt := range tests {
+		db := &MMDB{tree: tt.tree, recordSize: tt.recordSize, nodeCount: 1}
+		if got := db.record(0, 0); got != tt.left {
+			t.Errorf("%d-bit left record = %#x, want %#x", tt.recordSize, got, tt.left)
+		}
+		if got := db.record(0, 1); got != tt.right {
+			t.Errorf("%d-bit right record = %#x, want %#x", tt.recordSize, got, tt.right)
+		}
+	}
+}
+
+func TestDecoderPointer(t *testing.T) {
+	tests := []struct {
+		ctrl     byte
+		payload  []byte
+		want     uint
+		wantNext uint
+		wantErr  bool
+	}{
+		{ctrl: 0x21, payload: []byte{0x23}, want: 0x123, wantNext: 2},
+		{ctrl: 0x2a, payload: []byte{0x01, 0x02}, want: 0x020102 + 2048, wantNext: 3},
+		{ctrl: 0x33, payload: []byte{0x01, 0x02, 0x03}, want: 0x03010203 + 526336, wantNext: 4},
+		// With four bytes the size bits carry no value.
+		{ctrl: 0x3f, payload: []byte{0x12, 0x34, 0x56, 0x78}, want: 0x12345678, wantNext: 5},
+		{ctrl: 0x2a, payload: []byte{0x01}, wantErr: true},
+	}
+	for _, tt := range tests {
+		d := decoder{data: append([]byte{tt.ctrl}, tt.payload...)}
+		typ, bits, offset, err := d.control(0)
+		if err != nil || typ != typePointer {
+			t.Fatalf("control(%#x) = %d, %v, want a pointer", tt.ctrl, typ, err)
+		}
+		got, next, err := d.pointer(bits, offset)
+		if tt.wantErr {
+			if !errors.Is(err, ErrInvalidMMDB) {
+				t.Errorf("pointer(%#x % x) error = %v, want %v", tt.ctrl, tt.payload, err, ErrInvalidMMDB)
+			}
+			continue
+		}
+		if err != nil || got != tt.want || next != tt.wantNext {
+			t.Errorf("pointer(%#x % x) = %d, %d, %v, want %d, %d", tt.ctrl, tt.payload, got, next, err, tt.want, tt.wantNext)
+		}
+	}
+}
+
+func TestNewMMDBInvalid(t *testing.T) {
+	metadata := func(m map[string]interface{}) []byte {
+		var w mmdbWriter
+		w.write(m)
+		return append(append(make([]byte, 64), metadataMarker...), w.buf...)
+	}
+	tests := []struct {
+		name string
+		buf  []byte
+	}{
+		{"empty", nil},
+		{"no metadata", make([]byte, 64)},
+		{"truncated metadata", append(append([]byte(nil), metadataMarker...), 0xe2)},
+		{"metadata not a map", append(append([]byte(nil), metadataMarker...), 0x41, 'x')},
+		{"record size", metadata(map[string]interface{}{"node_count": uint32(1), "record_size": uint16(20)})},
+		{"tree past metadata", metadata(map[string]interface{}{"node_count": uint32(100), "record_size": uint16(24)})},
+		{"tree size overflow", metadata(map[string]interface{}{"node_count": uint64(1) << 62, "record_size": uint16(32), "ip_version": uint16(6)})},
+	}
+	for _, tt := range tests {
+		if _, err := NewMMDB(tt.buf); !errors.Is(err, ErrInvalidMMDB) {
+			t.Errorf("%s: NewMMDB() error = %v, want %v", tt.name, err, ErrInvalidMMDB)
+		}
+	}
+}
+
+// TestMMDBCorrupt checks that damaged files fail with ErrInvalidMMDB
+// rather than panicking.
+func TestMMDBCorrupt(t *testing.T) {
+	// Only the records before the long one keep the test quick.
+	data, networks := testData()
+	data, networks = data[:networks[2].data], networks[:2]
+	lookupAll := func(db *MMDB) error {
+		for _, ip := range []string{"81.2.69.142", "2001:db8::1", "10.1.2.3"} {
+			if _, err := db.Lookup(net.ParseIP(ip)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	buf := buildMMDB(t, 28, 6, networks, data)
+	for i := range buf {
+		db, err := NewMMDB(buf[:i])
+		if err == nil {
+			err = lookupAll(db)
+		}
+		if err != nil && !errors.Is(err, ErrInvalidMMDB) {
+			t.Fatalf("truncated to %d bytes: error = %v, want %v", i, err, ErrInvalidMMDB)
+		}
+	}
+
+	// A data section cut short under an intact tree.
+	db, err := NewMMDB(buildMMDB(t, 24, 6, networks, data[:3]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := lookupAll(db); !errors.Is(err, ErrInvalidMMDB) {
+		t.Errorf("short data section: Lookup() error = %v, want %v", err, ErrInvalidMMDB)
+	}
+
+	// A pointer to itself is cut off by the depth limit.
+	db, err = NewMMDB(buildMMDB(t, 24, 6, networks[:1], []byte{0x20, 0x00}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := lookupAll(db); !errors.Is(err, ErrInvalidMMDB) {
+		t.Errorf("pointer loop: Lookup() error = %v, want %v", err, ErrInvalidMMDB)
+	}
+
+	// Every byte of the data section set to 0xff in turn.
+	for i := range data {
+		damaged := append([]byte(nil), data...)
+		damaged[i] = 0xff
+		db, err := NewMMDB(buildMMDB(t, 32, 6, networks, damaged))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := lookupAll(db); err != nil && !errors.Is(err, ErrInvalidMMDB) {
+			t.Fatalf("data byte %d damaged: error = %v, want %v", i, err, ErrInvalidMMDB)
+		}
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"go_backend/internal/security"
 	"go_backend/internal/storage"
 	"go_backend/internal/utils"
+	"log"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -15,7 +16,9 @@ import (
 )
 
 // Register handles user registration by validating input, hashing password,
-// and inserting a new user record into the database.
+// and inserting a new user record into the database. A verification email is
+// sent to the new address; a delivery failure does not fail the registration,
+// since the user can request another one.
 //
 // It expects a JSON body containing email, username, and password fields.
 // Example request:
@@ -47,19 +50,25 @@ func Register(c *gin.Context) {
 	}
 
 	db := storage.GetPostgres()
+	userID := uuid.NewString()
 	_, err = db.Exec(`
 		INSERT INTO users (id, email, password, username)
 		VALUES ($1, $2, $3, $4)
-	`, uuid.NewString(), input.Email, hashedPassword, input.Username)
+	`, userID, input.Email, hashedPassword, input.Username)
 	if err != nil {
 		c.JSON(http.StatusConflict, gin.H{"error": "user already exists"})
 		return
 	}
 
+	if err := sendVerificationEmail(c.Request.Context(), userID, input.Email); err != nil {
+		log.Printf("auth: failed to send verification email: %v", err)
+	}
+
 	c.JSON(http.StatusCreated, gin.H{"message": "user registered successfully"})
 }
 
-// Login handles user authentication by verifying credentials and issuing a JWT.
+// Login handles user authentication by verifying credentials and issuing a
+// short-lived access JWT together with a refresh token.
 //
 // It expects a JSON body with email and password fields.
 // Example request:
@@ -72,9 +81,10 @@ func Register(c *gin.Context) {
 //
 // Responses:
 //
-//	200 OK – login successful, token set as cookie
+//	200 OK – login successful, access and refresh tokens set as cookies
 //	400 Bad Request – invalid input
 //	401 Unauthorized – invalid credentials
+//	403 Forbidden – email not verified (EMAIL_VERIFICATION_MODE=required)
 //	500 Internal Server Error – DB or token generation failure
 func Login(c *gin.Context) {
 	var input models.LoginInput
@@ -88,12 +98,13 @@ func Login(c *gin.Context) {
 	var (
 		userID         string
 		hashedPassword string
+		verified       bool
 	)
 
 	err := db.QueryRow(
-		"SELECT id, password FROM users WHERE email = $1",
+		"SELECT id, password, email_verified_at IS NOT NULL FROM users WHERE email = $1",
 		input.Email,
-	).Scan(&userID, &hashedPassword)
+	).Scan(&userID, &hashedPassword, &verified)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -109,13 +120,24 @@ func Login(c *gin.Context) {
 		return
 	}
 
-	token, err := security.GenerateJWT(userID)
+	if !verified && security.EmailVerificationMode() == security.VerificationRequired {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "email not verified",
+			"code":  "email_unverified",
+		})
+		return
+	}
+
+	pair, err := security.NewSession(userID, security.SessionMeta{IP: c.ClientIP(), UserAgent: c.Request.UserAgent()})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
 		return
 	}
 
-	utils.SetAuthCookie(c, token)
+	utils.SetAuthCookies(c, pair.AccessToken, pair.AccessExpiresAt, pair.RefreshToken, pair.RefreshExpiresAt)
 
-	c.JSON(http.StatusOK, gin.H{"message": "logged in successfully"})
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "logged in successfully",
+		"expires_at": pair.AccessExpiresAt,
+	})
 }
@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+
+	"go_backend/internal/models"
+	"go_backend/internal/security"
+	"go_backend/internal/storage"
+	"go_backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ChangePassword updates the password of the logged-in local user after
+// checking the current one. Every other session is revoked; the session
+// making the request stays logged in.
+//
+// Example request:
+//
+//	POST /api/user/change-password
+//	{
+//	  "current_password": "oldpassword",
+//	  "new_password": "newpassword"
+//	}
+//
+// Responses:
+//
+//	200 OK - password changed; other sessions revoked
+//	400 Bad Request - invalid input or account has no password (OAuth)
+//	401 Unauthorized - current password is incorrect
+//	500 Internal Server Error - hashing or DB failure
+func ChangePassword(c *gin.Context) {
+	var input models.ChangePasswordInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid input"})
+		return
+	}
+
+	userID := c.GetString("userID")
+	db := storage.GetPostgres()
+
+	var hashedPassword string
+	err := db.QueryRow(
+		"SELECT password FROM users WHERE id = $1 AND provider = 'local'",
+		userID,
+	).Scan(&hashedPassword)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "password login is not enabled for this account"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server error"})
+		return
+	}
+
+	if !utils.CheckPasswordHash(input.CurrentPassword, hashedPassword) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "incorrect password"})
+		return
+	}
+
+	newHash, err := utils.HashPassword(input.NewPassword)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to hash password"})
+		return
+	}
+
+	if _, err := db.Exec("UPDATE users SET password = $1 WHERE id = $2", newHash, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update password"})
+		return
+	}
+
+	if err := security.RevokeAllSessions(userID, c.GetString("sessionID")); err != nil {
+		log.Printf("auth: failed to revoke sessions for %s: %v", userID, err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "password changed successfully"})
+}
@@ -0,0 +1,155 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"go_backend/internal/mailer"
+	"go_backend/internal/models"
+	"go_backend/internal/security"
+	"go_backend/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	verifyResendKeyPrefix = "verify_resend:"
+	verifyResendInterval  = time.Minute
+)
+
+// VerifyEmail marks the account's email as verified using the token from
+// the verification link.
+//
+// Example request:
+//
+//	POST /verify-email
+//	{
+//	  "token": "<token from the emailed link>"
+//	}
+//
+// Responses:
+//
+//	200 OK - email verified (or already verified)
+//	400 Bad Request - invalid input or invalid/expired token
+//	500 Internal Server Error - DB failure
+func VerifyEmail(c *gin.Context) {
+	var input models.VerifyEmailInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid input"})
+		return
+	}
+
+	userID, email, err := security.ParseEmailVerificationToken(input.Token)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid or expired verification token"})
+		return
+	}
+
+	// The email must still match, so tokens die when the address changes.
+	res, err := storage.GetPostgres().Exec(`
+		UPDATE users SET email_verified_at = COALESCE(email_verified_at, NOW())
+		WHERE id = $1 AND email = $2`, userID, email)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server error"})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid or expired verification token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "email verified successfully"})
+}
+
+// ResendVerification sends a new verification email to an unverified local
+// account. Like ForgotPassword, the response does not reveal whether the
+// account exists. Resends are throttled to one per minute per account.
+//
+// Example request:
+//
+//	POST /verify-email/resend
+//	{
+//	  "email": "user@example.com"
+//	}
+func ResendVerification(c *gin.Context) {
+	var input models.ResendVerificationInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid input"})
+		return
+	}
+
+	respond := func() {
+		c.JSON(http.StatusOK, gin.H{"message": "if the account needs verification, an email has been sent"})
+	}
+
+	var userID string
+	err := storage.GetPostgres().QueryRow(`
+		SELECT id FROM users
+		WHERE email = $1 AND provider = 'local' AND email_verified_at IS NULL`,
+		input.Email,
+	).Scan(&userID)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("auth: resend-verification lookup failed: %v", err)
+		}
+		respond()
+		return
+	}
+
+	first, err := storage.RedisClient.SetNX(storage.Ctx, verifyResendKeyPrefix+userID, 1, verifyResendInterval).Result()
+	if err == nil && !first {
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"error":       "verification email already sent, please wait",
+			"retry_after": int(verifyResendInterval.Seconds()),
+		})
+		return
+	}
+
+	if err := sendVerificationEmail(c.Request.Context(), userID, input.Email); err != nil {
+		log.Printf("auth: failed to send verification email: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to send verification email"})
+		return
+	}
+	respond()
+}
+
+// sendVerificationEmail emails a signed verification link to the user.
+func sendVerificationEmail(ctx context.Context, userID, email string) error {
+	ctx, cancel := context.WithTimeout(ctx, mailTimeout)
+	defer cancel()
+
+	link := verificationLink(security.GenerateEmailVerificationToken(userID, email))
+	hours := int(security.EmailVerificationTTL.Hours())
+	return mailer.Default().Send(ctx, mailer.Message{
+		To:      email,
+		Subject: "Verify your Shortly email address",
+		Text: fmt.Sprintf("Welcome to Shortly!\n\n"+
+			"Confirm your email address by opening this link (valid for %d hours):\n%s\n", hours, link),
+		HTML: fmt.Sprintf(`<p>Welcome to Shortly!</p>`+
+			`<p><a href="%s">Confirm your email address</a> (valid for %d hours).</p>`, html.EscapeString(link), hours),
+	})
+}
+
+// verificationLink builds the frontend verification URL carrying the token.
+func verificationLink(token string) string {
+	base := os.Getenv("FRONTEND_VERIFY_URL")
+	if base == "" {
+		base = "http://localhost:3000/verify-email"
+	}
+	u, err := url.Parse(base)
+	if err != nil {
+		return base + "?token=" + url.QueryEscape(token)
+	}
+	q := u.Query()
+	q.Set("token", token)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
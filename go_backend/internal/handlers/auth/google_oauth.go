@@ -13,6 +13,7 @@ import (
 
 	"go_backend/internal/security"
 	"go_backend/internal/storage"
+	"go_backend/internal/utils"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -116,8 +117,8 @@ func GoogleCallback(c *gin.Context) {
 	case err == sql.ErrNoRows:
 		userID = uuid.New().String()
 		_, err = db.Exec(`
-			INSERT INTO users (id, email, username, avatar, provider, plan, provider_id)
-			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			INSERT INTO users (id, email, username, avatar, provider, plan, provider_id, email_verified_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())
 		`, userID, userinfo.Email, userinfo.Name, userinfo.Picture, "google", "free", userinfo.Sub)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
@@ -131,23 +132,24 @@ func GoogleCallback(c *gin.Context) {
 		return
 	}
 
-	// Generate JWT for session
-	jwtToken, err := security.GenerateJWT(userID)
+	// Start a session and set its cookies on the backend domain
+	pair, err := security.NewSession(userID, security.SessionMeta{IP: c.ClientIP(), UserAgent: c.Request.UserAgent()})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "JWT generation failed"})
 		return
 	}
+	utils.SetAuthCookies(c, pair.AccessToken, pair.AccessExpiresAt, pair.RefreshToken, pair.RefreshExpiresAt)
 
-	// Redirect user to frontend with token
+	// Redirect user to frontend; the session travels in the cookies only
 	redirect := os.Getenv("FRONTEND_REDIRECT_URL") // e.g. https://shortly.vercel.app/auth/callback
 	if redirect == "" {
 		redirect = "http://localhost:3000/auth/callback"
 	}
 
 	// Example: redirect user to billing page if state == "billing"
-	redirectURL := redirect + "?token=" + jwtToken
+	redirectURL := redirect
 	if strings.EqualFold(state, "billing") {
-		redirectURL += "&from=billing"
+		redirectURL += "?from=billing"
 	}
 
 	c.Redirect(http.StatusTemporaryRedirect, redirectURL)
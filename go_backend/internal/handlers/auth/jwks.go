@@ -0,0 +1,21 @@
+package auth
+
+import (
+	"net/http"
+
+	"go_backend/internal/security"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JWKS publishes the public keys Shortly tokens are signed with, so other
+// services can verify them without sharing a secret. Keys being rotated out
+// stay listed until they are removed from JWT_KEYS.
+//
+// Example request:
+//
+//	GET /.well-known/jwks.json
+func JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, security.JWKS())
+}
@@ -3,53 +3,67 @@
 package auth
 
 import (
-	"go_backend/internal/storage"
+	"go_backend/internal/models"
+	"go_backend/internal/security"
+	"go_backend/internal/utils"
 	"log"
 	"net/http"
-	"os"
-	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
-// Logout clears the user's JWT cookie and ends the session.
-// It dynamically adjusts cookie behavior for localhost and production environments.
-// Logout clears the user's JWT cookie and removes the token from Redis.
-// It dynamically adjusts cookie behavior for localhost and production environments.
+// Logout clears the user's auth cookies and revokes its session so the
+// access and refresh tokens stop working even if they were copied elsewhere.
+//
+// It does not require a valid access token: when it is missing or expired,
+// the session is found through the refresh token, taken from its cookie
+// (which is only sent to /api/auth paths) or from a refresh_token JSON field.
+// The cookies are cleared in every case.
 func Logout(c *gin.Context) {
-	// Retrieve token from cookie
-	token, err := c.Cookie("token")
-	if err == nil && token != "" {
-		// Remove token from Redis
-		if err := storage.RedisClient.Del(storage.Ctx, token).Err(); err != nil {
-			// Log the error but don't block logout
-			log.Printf("failed to delete token from Redis: %v", err)
-		}
+	var err error
+	if claims, verr := security.ValidateJWTFromCookie(c); verr == nil {
+		err = security.RevokeSession(claims.UserID, claims.ID)
+	} else if token := refreshTokenFromRequest(c); token != "" {
+		err = security.RevokeRefreshSession(token)
 	}
-
-	// Determine frontend origin
-	frontendOrigin := os.Getenv("FRONTEND_ORIGIN")
-	if frontendOrigin == "" {
-		frontendOrigin = "http://localhost:3000"
+	if err != nil {
+		// Log the error but don't block logout
+		log.Printf("failed to revoke session: %v", err)
 	}
 
-	isLocal := strings.Contains(frontendOrigin, "localhost")
-	cookieDomain := "localhost"
-	secure := false
-	sameSite := http.SameSiteLaxMode
+	utils.ClearAuthCookies(c)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "logged out successfully",
+	})
+}
 
-	if !isLocal {
-		// Production domain adjustments
-		cookieDomain = strings.Split(strings.TrimPrefix(strings.TrimPrefix(frontendOrigin, "https://"), "http://"), ":")[0]
-		secure = true
-		sameSite = http.SameSiteNoneMode
+// LogoutAll revokes every session of the current user, logging them out on
+// all devices, and clears the cookie on this one.
+func LogoutAll(c *gin.Context) {
+	if err := security.RevokeAllSessions(c.GetString("userID"), ""); err != nil {
+		log.Printf("failed to revoke sessions: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke sessions"})
+		return
 	}
 
-	// Invalidate cookie by setting MaxAge < 0
-	c.SetSameSite(sameSite)
-	c.SetCookie("token", "", -1, "/", cookieDomain, secure, true)
+	utils.ClearAuthCookies(c)
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "logged out successfully",
+		"message": "logged out of all devices",
 	})
 }
+
+// refreshTokenFromRequest returns the refresh token from its cookie or, for
+// clients that do not use cookies, from the JSON body. It returns "" if
+// neither is present.
+func refreshTokenFromRequest(c *gin.Context) string {
+	if token, err := c.Cookie(utils.RefreshCookieName); err == nil && token != "" {
+		return token
+	}
+	var input models.RefreshInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		return ""
+	}
+	return input.RefreshToken
+}
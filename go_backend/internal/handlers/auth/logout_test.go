@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go_backend/internal/security"
+	"go_backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newLogoutRouter() *gin.Engine {
+	r := gin.New()
+	r.POST("/api/auth/logout", Logout)
+	return r
+}
+
+func TestLogout(t *testing.T) {
+	tests := []struct {
+		name string
+		// request builds the logout request for a session's token pair.
+		request func(*security.TokenPair) *http.Request
+		revoked bool
+	}{
+		{
+			name: "access token",
+			request: func(p *security.TokenPair) *http.Request {
+				req := httptest.NewRequest(http.MethodPost, "/api/auth/logout", nil)
+				req.AddCookie(&http.Cookie{Name: utils.AccessCookieName, Value: p.AccessToken})
+				return req
+			},
+			revoked: true,
+		},
+		{
+			name: "expired access token and refresh cookie",
+			request: func(p *security.TokenPair) *http.Request {
+				req := httptest.NewRequest(http.MethodPost, "/api/auth/logout", nil)
+				req.AddCookie(&http.Cookie{Name: utils.AccessCookieName, Value: "expired"})
+				req.AddCookie(&http.Cookie{Name: utils.RefreshCookieName, Value: p.RefreshToken})
+				return req
+			},
+			revoked: true,
+		},
+		{
+			name: "refresh token in body",
+			request: func(p *security.TokenPair) *http.Request {
+				req := httptest.NewRequest(http.MethodPost, "/api/auth/logout",
+					strings.NewReader(`{"refresh_token":"`+p.RefreshToken+`"}`))
+				req.Header.Set("Content-Type", "application/json")
+				return req
+			},
+			revoked: true,
+		},
+		{
+			name: "no tokens",
+			request: func(*security.TokenPair) *http.Request {
+				return httptest.NewRequest(http.MethodPost, "/api/auth/logout", nil)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("JWT_SECRET", "test-secret")
+			setupStores(t)
+			pair, err := security.NewSession(testUserID, security.SessionMeta{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			w := httptest.NewRecorder()
+			newLogoutRouter().ServeHTTP(w, tt.request(pair))
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+			}
+
+			cleared := map[string]bool{}
+			for _, c := range w.Result().Cookies() {
+				cleared[c.Name] = c.MaxAge < 0
+			}
+			if !cleared[utils.AccessCookieName] || !cleared[utils.RefreshCookieName] {
+				t.Errorf("cookies cleared = %v, want both auth cookies", cleared)
+			}
+
+			sessions, err := security.ListSessions(testUserID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if revoked := len(sessions) == 0; revoked != tt.revoked {
+				t.Errorf("session revoked = %v, want %v", revoked, tt.revoked)
+			}
+		})
+	}
+}
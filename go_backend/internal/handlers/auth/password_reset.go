@@ -0,0 +1,246 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go_backend/internal/mailer"
+	"go_backend/internal/models"
+	"go_backend/internal/security"
+	"go_backend/internal/storage"
+	"go_backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// mailTimeout bounds how long sending one email may take.
+const mailTimeout = 15 * time.Second
+
+const (
+	resetRequestKeyPrefix = "pwreset_request:"
+	resetRequestInterval  = time.Minute
+)
+
+// pendingMail tracks emails still being sent in the background.
+var pendingMail sync.WaitGroup
+
+// ForgotPassword emails a single-use password reset link to a local account.
+//
+// It expects a JSON body with an email field.
+// Example request:
+//
+//	POST /forgot-password
+//	{
+//	  "email": "user@example.com"
+//	}
+//
+// The response is the same whether or not the account exists, so the
+// endpoint cannot be used to discover registered emails. It is written
+// before the email is sent, so its timing does not depend on the account
+// either. Requests are throttled to one per minute per address; throttled
+// requests get the same response but no email.
+//
+// Responses:
+//
+//	200 OK - reset link sent if the account exists
+//	400 Bad Request - invalid input
+func ForgotPassword(c *gin.Context) {
+	var input models.ForgotPasswordInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid input"})
+		return
+	}
+
+	respond := func() {
+		c.JSON(http.StatusOK, gin.H{"message": "if the account exists, a reset link has been sent"})
+	}
+
+	// Throttle by address before the lookup so that registered and unknown
+	// emails are handled alike.
+	key := resetRequestKeyPrefix + strings.ToLower(input.Email)
+	first, err := storage.RedisClient.SetNX(storage.Ctx, key, 1, resetRequestInterval).Result()
+	if err == nil && !first {
+		respond()
+		return
+	}
+
+	var userID string
+	err = storage.GetPostgres().QueryRow(
+		"SELECT id FROM users WHERE email = $1 AND provider = 'local'",
+		input.Email,
+	).Scan(&userID)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("auth: forgot-password lookup failed: %v", err)
+		}
+		respond()
+		return
+	}
+
+	email := input.Email
+	sendInBackground("reset email", func(ctx context.Context) error {
+		token, err := security.IssuePasswordResetToken(userID)
+		if err != nil {
+			return err
+		}
+		return mailer.Default().Send(ctx, passwordResetMessage(email, resetLink(token)))
+	})
+
+	respond()
+}
+
+// ResetPassword sets a new password using a token from ForgotPassword and
+// revokes every session issued before the reset.
+//
+// Example request:
+//
+//	POST /reset-password
+//	{
+//	  "token": "<token from the emailed link>",
+//	  "password": "newsecurepassword"
+//	}
+//
+// Responses:
+//
+//	200 OK - password changed; existing sessions revoked
+//	400 Bad Request - invalid input or invalid/expired token
+//	500 Internal Server Error - hashing or DB failure
+func ResetPassword(c *gin.Context) {
+	var input models.ResetPasswordInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid input"})
+		return
+	}
+
+	userID, err := security.PasswordResetUser(input.Token)
+	if err != nil {
+		respondResetTokenError(c, err)
+		return
+	}
+
+	hashedPassword, err := utils.HashPassword(input.Password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to hash password"})
+		return
+	}
+
+	tx, err := storage.GetPostgres().Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server error"})
+		return
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(
+		"UPDATE users SET password = $1 WHERE id = $2 AND provider = 'local'",
+		hashedPassword, userID,
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update password"})
+		return
+	}
+	// The account was deleted or is no longer a local one since the token
+	// was issued.
+	if n, err := res.RowsAffected(); err != nil || n != 1 {
+		respondResetTokenError(c, security.ErrInvalidResetToken)
+		return
+	}
+
+	// Use the token up only once the update has succeeded, and roll back if a
+	// concurrent request used it first.
+	if _, err := security.ConsumePasswordResetToken(input.Token); err != nil {
+		respondResetTokenError(c, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update password"})
+		return
+	}
+
+	if err := security.RevokeAllSessions(userID, ""); err != nil {
+		log.Printf("auth: failed to revoke sessions for %s: %v", userID, err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "password reset successfully"})
+}
+
+// respondResetTokenError reports an unusable reset token as 400 and any
+// other failure as 500.
+func respondResetTokenError(c *gin.Context, err error) {
+	if errors.Is(err, security.ErrInvalidResetToken) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid or expired reset token"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "server error"})
+}
+
+// sendInBackground runs send after the handler returns, so response times do
+// not depend on the mail server. Failures can only be logged.
+func sendInBackground(what string, send func(ctx context.Context) error) {
+	pendingMail.Add(1)
+	go func() {
+		defer pendingMail.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), mailTimeout)
+		defer cancel()
+		if err := send(ctx); err != nil {
+			log.Printf("auth: failed to send %s: %v", what, err)
+		}
+	}()
+}
+
+// WaitForMail blocks until emails sent in the background have been handed
+// to the mail server or ctx is done. The server calls it on shutdown.
+func WaitForMail(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		pendingMail.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// resetLink builds the frontend reset URL carrying the token.
+func resetLink(token string) string {
+	base := os.Getenv("FRONTEND_RESET_URL")
+	if base == "" {
+		base = "http://localhost:3000/reset"
+	}
+	u, err := url.Parse(base)
+	if err != nil {
+		return base + "?token=" + url.QueryEscape(token)
+	}
+	q := u.Query()
+	q.Set("token", token)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// passwordResetMessage renders the reset email.
+func passwordResetMessage(to, link string) mailer.Message {
+	minutes := int(security.PasswordResetTTL.Minutes())
+	return mailer.Message{
+		To:      to,
+		Subject: "Reset your Shortly password",
+		Text: fmt.Sprintf("We received a request to reset your Shortly password.\n\n"+
+			"Open this link to choose a new password (valid for %d minutes):\n%s\n\n"+
+			"If you did not request this, you can ignore this email.\n", minutes, link),
+		HTML: fmt.Sprintf(`<p>We received a request to reset your Shortly password.</p>`+
+			`<p><a href="%s">Choose a new password</a> (valid for %d minutes).</p>`+
+			`<p>If you did not request this, you can ignore this email.</p>`, html.EscapeString(link), minutes),
+	}
+}
@@ -0,0 +1,279 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"go_backend/internal/mailer"
+	"go_backend/internal/security"
+	"go_backend/internal/storage"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	testUserID = "user-1"
+	testEmail  = "user@example.com"
+)
+
+const (
+	userLookupQuery     = `SELECT id FROM users WHERE email = \$1 AND provider = 'local'`
+	passwordUpdateQuery = `UPDATE users SET password = \$1 WHERE id = \$2 AND provider = 'local'`
+)
+
+// setupStores points storage at a sqlmock database and a miniredis server
+// and installs a mailer.Fake for the duration of the test.
+func setupStores(t *testing.T) (sqlmock.Sqlmock, *miniredis.Miniredis, *mailer.Fake) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	storage.SetPostgres(db)
+
+	mr := miniredis.RunT(t)
+	storage.RedisClient = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { storage.RedisClient.Close() })
+
+	fake := mailer.NewFake()
+	prev := mailer.Default()
+	mailer.SetDefault(fake)
+	t.Cleanup(func() { mailer.SetDefault(prev) })
+	return mock, mr, fake
+}
+
+func post(handler gin.HandlerFunc, body string) *httptest.ResponseRecorder {
+	r := gin.New()
+	r.POST("/", handler)
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// forgotPassword requests a reset link for testEmail and waits for the
+// background email.
+func forgotPassword(t *testing.T) *httptest.ResponseRecorder {
+	t.Helper()
+	w := post(ForgotPassword, `{"email":"`+testEmail+`"}`)
+	if err := WaitForMail(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return w
+}
+
+var tokenParam = regexp.MustCompile(`\?token=(\S+)`)
+
+// sentToken returns the reset token from the only email sent so far.
+func sentToken(t *testing.T, fake *mailer.Fake) string {
+	t.Helper()
+	sent := fake.Sent()
+	if len(sent) != 1 {
+		t.Fatalf("sent %d emails, want 1", len(sent))
+	}
+	if sent[0].To != testEmail {
+		t.Errorf("email sent to %q, want %q", sent[0].To, testEmail)
+	}
+	m := tokenParam.FindStringSubmatch(sent[0].Text)
+	if m == nil {
+		t.Fatalf("no reset link in email:\n%s", sent[0].Text)
+	}
+	token, err := url.QueryUnescape(m[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func resetPassword(token string) *httptest.ResponseRecorder {
+	return post(ResetPassword, `{"token":"`+token+`","password":"n3w-password"}`)
+}
+
+func TestForgotPasswordSendsResetLink(t *testing.T) {
+	mock, _, fake := setupStores(t)
+	mock.ExpectQuery(userLookupQuery).
+		WithArgs(testEmail).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(testUserID))
+
+	if w := forgotPassword(t); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+	userID, err := security.PasswordResetUser(sentToken(t, fake))
+	if err != nil || userID != testUserID {
+		t.Errorf("PasswordResetUser() = %q, %v, want %q", userID, err, testUserID)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestForgotPasswordUnknownEmail(t *testing.T) {
+	mock, _, fake := setupStores(t)
+	mock.ExpectQuery(userLookupQuery).
+		WithArgs(testEmail).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	w := forgotPassword(t)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if sent := fake.Sent(); len(sent) != 0 {
+		t.Errorf("sent %d emails, want none", len(sent))
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestForgotPasswordCooldown(t *testing.T) {
+	mock, mr, fake := setupStores(t)
+	mock.ExpectQuery(userLookupQuery).
+		WithArgs(testEmail).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(testUserID))
+
+	first := forgotPassword(t)
+	token := sentToken(t, fake)
+
+	// The second request is answered alike but neither looks the account
+	// up nor replaces the emailed token.
+	second := forgotPassword(t)
+	if second.Code != first.Code || second.Body.String() != first.Body.String() {
+		t.Errorf("throttled response = %d %s, want %d %s", second.Code, second.Body, first.Code, first.Body)
+	}
+	if sent := fake.Sent(); len(sent) != 1 {
+		t.Errorf("sent %d emails, want 1", len(sent))
+	}
+	if _, err := security.PasswordResetUser(token); err != nil {
+		t.Errorf("PasswordResetUser() error = %v after throttled request", err)
+	}
+
+	mr.FastForward(resetRequestInterval)
+	mock.ExpectQuery(userLookupQuery).
+		WithArgs(testEmail).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(testUserID))
+	forgotPassword(t)
+	if sent := fake.Sent(); len(sent) != 2 {
+		t.Errorf("sent %d emails after the cooldown, want 2", len(sent))
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestResetPassword(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	mock, _, fake := setupStores(t)
+	mock.ExpectQuery(userLookupQuery).
+		WithArgs(testEmail).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(testUserID))
+	forgotPassword(t)
+	token := sentToken(t, fake)
+
+	for range 2 {
+		if _, err := security.NewSession(testUserID, security.SessionMeta{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(passwordUpdateQuery).
+		WithArgs(sqlmock.AnyArg(), testUserID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if w := resetPassword(token); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d; body: %s", w.Code, http.StatusOK, w.Body)
+	}
+	if sessions, err := security.ListSessions(testUserID); err != nil || len(sessions) != 0 {
+		t.Errorf("ListSessions() = %d sessions, %v, want none", len(sessions), err)
+	}
+
+	// Tokens are single-use.
+	if w := resetPassword(token); w.Code != http.StatusBadRequest {
+		t.Errorf("reusing token: status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestResetPasswordExpiredToken(t *testing.T) {
+	mock, mr, _ := setupStores(t)
+	token, err := security.IssuePasswordResetToken(testUserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mr.FastForward(security.PasswordResetTTL)
+	if w := resetPassword(token); w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestResetPasswordUnknownUser(t *testing.T) {
+	t.Setenv("JWT_SECRET", "test-secret")
+	mock, _, _ := setupStores(t)
+	token, err := security.IssuePasswordResetToken(testUserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := security.NewSession(testUserID, security.SessionMeta{}); err != nil {
+		t.Fatal(err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(passwordUpdateQuery).
+		WithArgs(sqlmock.AnyArg(), testUserID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	if w := resetPassword(token); w.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	if sessions, err := security.ListSessions(testUserID); err != nil || len(sessions) != 1 {
+		t.Errorf("ListSessions() = %d sessions, %v, want the session kept", len(sessions), err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestResetPasswordKeepsTokenWhenUpdateFails(t *testing.T) {
+	mock, _, _ := setupStores(t)
+	token, err := security.IssuePasswordResetToken(testUserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(passwordUpdateQuery).
+		WithArgs(sqlmock.AnyArg(), testUserID).
+		WillReturnError(errors.New("connection reset"))
+	mock.ExpectRollback()
+
+	if w := resetPassword(token); w.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusInternalServerError)
+	}
+	if userID, err := security.PasswordResetUser(token); err != nil || userID != testUserID {
+		t.Errorf("PasswordResetUser() = %q, %v, want the token to stay valid", userID, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+
+	"go_backend/internal/models"
+	"go_backend/internal/security"
+	"go_backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Refresh exchanges a refresh token for a new access/refresh token pair.
+// The refresh token is read from its cookie, or from the JSON body for
+// clients that do not use cookies; those clients get the new pair in the
+// response body as well.
+//
+// Every refresh token can be used once. Presenting one that was already
+// rotated revokes the session, since only a copy of the token can do that.
+//
+// Example request:
+//
+//	POST /api/auth/refresh
+//	{
+//	  "refresh_token": "<optional when the cookie is set>"
+//	}
+//
+// Responses:
+//
+//	200 OK - new tokens issued (cookies set)
+//	401 Unauthorized - missing, invalid, expired or reused refresh token
+//	500 Internal Server Error - Redis or token generation failure
+func Refresh(c *gin.Context) {
+	fromBody := false
+	token, err := c.Cookie(utils.RefreshCookieName)
+	if err != nil || token == "" {
+		var input models.RefreshInput
+		if err := c.ShouldBindJSON(&input); err != nil || input.RefreshToken == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "refresh token not provided"})
+			return
+		}
+		token, fromBody = input.RefreshToken, true
+	}
+
+	pair, err := security.RefreshSession(token, security.SessionMeta{IP: c.ClientIP(), UserAgent: c.Request.UserAgent()})
+	if err != nil {
+		switch {
+		case errors.Is(err, security.ErrRefreshTokenReused):
+			utils.ClearAuthCookies(c)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "refresh token reuse detected, session revoked"})
+		case errors.Is(err, security.ErrInvalidRefreshToken):
+			utils.ClearAuthCookies(c)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired refresh token"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to refresh session"})
+		}
+		return
+	}
+
+	utils.SetAuthCookies(c, pair.AccessToken, pair.AccessExpiresAt, pair.RefreshToken, pair.RefreshExpiresAt)
+
+	resp := gin.H{
+		"message":    "session refreshed",
+		"expires_at": pair.AccessExpiresAt,
+	}
+	if fromBody {
+		resp["access_token"] = pair.AccessToken
+		resp["refresh_token"] = pair.RefreshToken
+	}
+	c.JSON(http.StatusOK, resp)
+}
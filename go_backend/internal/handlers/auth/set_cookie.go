@@ -2,41 +2,36 @@ package auth
 
 import (
 	"net/http"
-	"os"
-	"strings"
+
+	"go_backend/internal/security"
+	"go_backend/internal/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
-
+// SetCookieHandler stores tokens received out of band (e.g. from the OAuth
+// callback redirect) as auth cookies. The access token is validated first and
+// the cookie expires together with it, matching Login.
 func SetCookieHandler(c *gin.Context) {
 	var body struct {
-		Token string `json:"token"`
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
 	}
 	if err := c.BindJSON(&body); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
 		return
 	}
 
-	frontendOrigin := os.Getenv("FRONTEND_ORIGIN")
-	if frontendOrigin == "" {
-		frontendOrigin = "http://localhost:3000"
-	}
-
-	isLocalhost := strings.Contains(frontendOrigin, "localhost")
-	cookieDomain := "localhost"
-	if !isLocalhost {
-		cookieDomain = "shortly-url-shortener-showcase.vercel.app" // adjust as needed
+	claims, err := security.ValidateJWTFromString(body.Token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return
 	}
 
-	secure := !isLocalhost // ✅ Only true in production
-	sameSite := http.SameSiteLaxMode
-	if secure {
-		sameSite = http.SameSiteNoneMode
+	utils.SetAuthCookie(c, body.Token, claims.ExpiresAt.Time)
+	if body.RefreshToken != "" {
+		utils.SetRefreshCookie(c, body.RefreshToken, claims.IssuedAt.Add(security.RefreshTokenTTL()))
 	}
 
-	c.SetSameSite(sameSite)
-	c.SetCookie("token", body.Token, 24*60*60, "/", cookieDomain, secure, true)
-
 	c.JSON(http.StatusOK, gin.H{"message": "Cookie set"})
 }
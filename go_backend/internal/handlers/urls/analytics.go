@@ -0,0 +1,247 @@
+package urls
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go_backend/internal/analytics"
+	"go_backend/internal/authz"
+	"go_backend/internal/collections"
+	"go_backend/internal/plans"
+	"go_backend/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultAnalyticsWindow = 30 * 24 * time.Hour
+	defaultBreakdownLimit  = 10
+	maxBreakdownLimit      = 100
+)
+
+// GetLinkAnalytics returns total and unique visitors plus the top referrers,
+// countries, cities, browsers, operating systems, device types and A/B
+// variants for a shortlink owned by the caller. Ownership is verified by
+// middleware.RequireLinkOwner; ranges are clamped to the plan's retention.
+//
+// Example request:
+//
+//	GET /api/user/shortlinks/:id/analytics?from=2025-01-01&to=2025-02-01&limit=10
+func GetLinkAnalytics(c *gin.Context) {
+	link, _ := authz.LinkFromContext(c)
+	respondSummary(c, link.UserID, link.ID, analytics.LinkScope(link.ID))
+}
+
+// GetLinkTimeSeries returns clicks and unique visitors for a shortlink owned
+// by the caller, bucketed by hour, day or week.
+//
+// Example request:
+//
+//	GET /api/user/shortlinks/:id/analytics/timeseries?interval=hour&from=2025-01-01T00:00:00Z
+func GetLinkTimeSeries(c *gin.Context) {
+	link, _ := authz.LinkFromContext(c)
+	respondTimeSeries(c, link.UserID, link.ID, analytics.LinkScope(link.ID))
+}
+
+// GetTagAnalytics is GetLinkAnalytics for all links carrying one of the
+// caller's tags. The summary also lists the most visited links.
+//
+// Example request:
+//
+//	GET /api/user/tags/:id/analytics?from=2025-01-01
+func GetTagAnalytics(c *gin.Context) {
+	if userID, id, ok := ownedTag(c); ok {
+		respondSummary(c, userID, id, analytics.TagScope(id))
+	}
+}
+
+// GetTagTimeSeries is GetLinkTimeSeries for all links carrying one of the
+// caller's tags.
+//
+// Example request:
+//
+//	GET /api/user/tags/:id/analytics/timeseries?interval=week
+func GetTagTimeSeries(c *gin.Context) {
+	if userID, id, ok := ownedTag(c); ok {
+		respondTimeSeries(c, userID, id, analytics.TagScope(id))
+	}
+}
+
+// GetFolderAnalytics is GetLinkAnalytics for all links in one of the
+// caller's folders. The summary also lists the most visited links.
+//
+// Example request:
+//
+//	GET /api/user/folders/:id/analytics?from=2025-01-01
+func GetFolderAnalytics(c *gin.Context) {
+	if userID, id, ok := ownedFolder(c); ok {
+		respondSummary(c, userID, id, analytics.FolderScope(id))
+	}
+}
+
+// GetFolderTimeSeries is GetLinkTimeSeries for all links in one of the
+// caller's folders.
+//
+// Example request:
+//
+//	GET /api/user/folders/:id/analytics/timeseries?interval=week
+func GetFolderTimeSeries(c *gin.Context) {
+	if userID, id, ok := ownedFolder(c); ok {
+		respondTimeSeries(c, userID, id, analytics.FolderScope(id))
+	}
+}
+
+// ownedTag checks that the :id tag belongs to the caller and writes 404
+// otherwise.
+func ownedTag(c *gin.Context) (string, string, bool) {
+	userID, _ := authz.UserID(c)
+	t, err := collections.OwnedTag(storage.GetPostgres(), userID, c.Param("id"))
+	if err != nil {
+		respondCollectionError(c, err, "tag not found")
+		return "", "", false
+	}
+	return userID, t.ID, true
+}
+
+// ownedFolder checks that the :id folder belongs to the caller and writes
+// 404 otherwise.
+func ownedFolder(c *gin.Context) (string, string, bool) {
+	userID, _ := authz.UserID(c)
+	f, err := collections.OwnedFolder(storage.GetPostgres(), userID, c.Param("id"))
+	if err != nil {
+		respondCollectionError(c, err, "folder not found")
+		return "", "", false
+	}
+	return userID, f.ID, true
+}
+
+// respondSummary writes the analytics summary of scope for the owner userID.
+func respondSummary(c *gin.Context, userID, id string, scope analytics.Scope) {
+	r, err := parseRange(c, analytics.IntervalDay)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	limit := defaultBreakdownLimit
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxBreakdownLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 100"})
+			return
+		}
+		limit = n
+	}
+
+	if !clampToRetention(c, userID, &r) {
+		return
+	}
+
+	summary, err := analytics.Summarize(storage.GetPostgres(), scope, r, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load analytics"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":      id,
+		"from":    r.From,
+		"to":      r.To,
+		"summary": summary,
+	})
+}
+
+// respondTimeSeries writes the analytics time series of scope for the owner
+// userID.
+func respondTimeSeries(c *gin.Context, userID, id string, scope analytics.Scope) {
+	r, err := parseRange(c, analytics.IntervalDay)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !clampToRetention(c, userID, &r) {
+		return
+	}
+
+	buckets, err := analytics.TimeSeries(storage.GetPostgres(), scope, r)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load analytics"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":       id,
+		"from":     r.From,
+		"to":       r.To,
+		"interval": r.Interval,
+		"buckets":  buckets,
+	})
+}
+
+// clampToRetention moves the start of r forward to the analytics retention
+// window of the owner's plan. It writes an error response and returns false
+// if the plan cannot be loaded or the whole range lies outside the window.
+func clampToRetention(c *gin.Context, userID string, r *analytics.Range) bool {
+	limits, err := plans.ForUser(storage.GetPostgres(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server error"})
+		return false
+	}
+	if start := limits.RetentionStart(time.Now()); r.From.Before(start) {
+		r.From = start
+	}
+	if !r.From.Before(r.To) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "requested range is outside the analytics retention of your plan",
+			"limit": "analytics_retention_days",
+			"plan":  limits.Plan,
+			"max":   limits.AnalyticsRetentionDays,
+		})
+		return false
+	}
+	return true
+}
+
+// parseRange reads from, to and interval query parameters. Timestamps may be
+// RFC 3339 or plain dates; the default range is the last 30 days.
+func parseRange(c *gin.Context, defaultInterval string) (analytics.Range, error) {
+	r := analytics.Range{
+		To:       time.Now().UTC(),
+		Interval: c.DefaultQuery("interval", defaultInterval),
+	}
+
+	if v := c.Query("to"); v != "" {
+		t, err := parseTime(v)
+		if err != nil {
+			return r, errors.New("to must be an RFC 3339 timestamp or YYYY-MM-DD date")
+		}
+		r.To = t
+	}
+
+	r.From = r.To.Add(-defaultAnalyticsWindow)
+	if v := c.Query("from"); v != "" {
+		t, err := parseTime(v)
+		if err != nil {
+			return r, errors.New("from must be an RFC 3339 timestamp or YYYY-MM-DD date")
+		}
+		r.From = t
+	}
+
+	if err := r.Validate(); err != nil {
+		return r, errors.New("invalid range: from must precede to, interval must be hour, day or week, and at most 2000 buckets are allowed")
+	}
+	return r, nil
+}
+
+// parseTime accepts RFC 3339 timestamps and YYYY-MM-DD dates. Times are
+// returned in UTC, since visited_at is a timestamp without time zone and
+// would otherwise lose the offset.
+func parseTime(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t.UTC(), nil
+	}
+	return time.Parse("2006-01-02", v)
+}
@@ -0,0 +1,681 @@
+package urls
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go_backend/internal/authz"
+	"go_backend/internal/collections"
+	"go_backend/internal/models"
+	"go_backend/internal/plans"
+	"go_backend/internal/storage"
+	"go_backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	// bulkMaxRows caps the links created by one bulk request.
+	bulkMaxRows = 5000
+	// bulkMaxBytes caps the request body of a bulk request.
+	bulkMaxBytes = 8 << 20
+	// bulkInsertChunk rows are inserted per statement (18 parameters each).
+	bulkInsertChunk = 500
+	// bulkSlugAttempts bounds the rounds of random slug generation.
+	bulkSlugAttempts = 5
+)
+
+// bulkColumns maps the accepted CSV header names to URLRequest fields.
+var bulkColumns = map[string][]string{
+	"original_url": {"original_url", "url", "destination", "long_url"},
+	"slug":         {"slug", "custom_slug", "back_half"},
+	"expires_at":   {"expires_at"},
+	"max_clicks":   {"max_clicks"},
+	"active_from":  {"active_from"},
+	"fallback_url": {"fallback_url"},
+	"tags":         {"tags"},
+	"folder_id":    {"folder_id", "folder"},
+	"utm_source":   {"utm_source"},
+	"utm_medium":   {"utm_medium"},
+	"utm_campaign": {"utm_campaign"},
+	"utm_term":     {"utm_term"},
+	"utm_content":  {"utm_content"},
+}
+
+// bulkResult reports the outcome of one input row. Row is 1-based and
+// counts data rows only, so it matches the array index + 1 for JSON input.
+type bulkResult struct {
+	Row      int    `json:"row"`
+	Status   string `json:"status"` // "created" or "error"
+	ID       string `json:"id,omitempty"`
+	Slug     string `json:"slug,omitempty"`
+	ShortURL string `json:"short_url,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// bulkRow is a row that passed validation and is waiting for its slug.
+type bulkRow struct {
+	index     int
+	input     models.URLRequest
+	custom    bool
+	id        string
+	slug      string
+	createdAt time.Time
+	clicks    int64
+}
+
+// BulkShortenURLs creates many links in one request, in a single
+// transaction. The body is either a JSON array of URLRequest objects or a
+// CSV file (Content-Type text/csv, or a multipart upload in the "file"
+// field) with a header row naming the columns original_url, slug,
+// expires_at, max_clicks, active_from, fallback_url, tags, folder_id and
+// utm_source, utm_medium, utm_campaign, utm_term and utm_content; only
+// original_url is required. In CSV, tags are separated by commas or
+// semicolons within their field.
+//
+// Rows are validated individually and invalid rows or taken slugs are
+// reported in the results without failing the others. Plan quotas are
+// checked for all valid rows at once: if they do not fit, nothing is
+// created and 403 is returned. QR codes are not generated in bulk, and
+// password-protected links must be created one at a time.
+//
+// Example request:
+//
+//	POST /api/user/shorten/bulk
+//	[
+//	  {"original_url": "https://example.com/a"},
+//	  {"original_url": "https://example.com/b", "slug": "spring-sale"}
+//	]
+//
+// Responses:
+//
+//	200 OK - per-row results in "results"
+//	400 Bad Request - unreadable body, no rows or more than bulkMaxRows
+//	403 Forbidden - the valid rows exceed a plan limit
+//	500 Internal Server Error - DB failure
+func BulkShortenURLs(c *gin.Context) {
+	userID, ok := authz.UserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, bulkMaxBytes)
+	inputs, err := readBulkInput(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(inputs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no links provided"})
+		return
+	}
+	if len(inputs) > bulkMaxRows {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("at most %d links per request", bulkMaxRows)})
+		return
+	}
+
+	results := make([]bulkResult, len(inputs))
+	rows := validateBulkRows(inputs, results, time.Now().UTC())
+
+	db := storage.GetPostgres()
+	rows, err = checkBulkFolders(db, userID, rows, results)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server error"})
+		return
+	}
+	customSlugs := 0
+	for _, r := range rows {
+		if r.custom {
+			customSlugs++
+		}
+	}
+	if len(rows) > 0 {
+		limits, err := plans.ForUser(db, userID)
+		if err == nil {
+			err = plans.CheckLinkCreation(db, userID, limits, len(rows), customSlugs)
+		}
+		if err != nil {
+			respondPlanError(c, err)
+			return
+		}
+	}
+
+	rows, err = assignBulkSlugs(db, rows, results)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server error"})
+		return
+	}
+
+	created, err := insertBulkRows(db, userID, rows)
+	if err != nil {
+		log.Printf("bulk shorten: insert failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database insert failed"})
+		return
+	}
+
+	baseURL := getBaseURLFromRequest(c)
+	var cached []SlugCache
+	var cachedSlugs []string
+	for _, r := range rows {
+		if !created[r.slug] {
+			// Lost a race with a concurrent insert of the same slug.
+			results[r.index] = bulkResult{Row: r.index + 1, Status: "error", Error: "custom slug already in use"}
+			continue
+		}
+		results[r.index] = bulkResult{
+			Row:      r.index + 1,
+			Status:   "created",
+			ID:       r.id,
+			Slug:     r.slug,
+			ShortURL: baseURL + "/" + r.slug,
+		}
+		cached = append(cached, slugCacheFor(r.input, r.id, userID))
+		cachedSlugs = append(cachedSlugs, r.slug)
+	}
+	cacheBulkSlugs(cached, cachedSlugs)
+
+	c.JSON(http.StatusOK, gin.H{
+		"created": len(cached),
+		"failed":  len(results) - len(cached),
+		"results": results,
+	})
+}
+
+// readBulkInput decodes the request body as a JSON array or as CSV,
+// depending on its content type.
+func readBulkInput(c *gin.Context) ([]models.URLRequest, error) {
+	if isCSVUpload(c) {
+		body, err := openCSVUpload(c)
+		if err != nil {
+			return nil, err
+		}
+		defer body.Close()
+		return parseBulkCSV(body)
+	}
+
+	var inputs []models.URLRequest
+	if err := json.NewDecoder(c.Request.Body).Decode(&inputs); err != nil {
+		return nil, errors.New("body must be a JSON array of links")
+	}
+	return inputs, nil
+}
+
+// isCSVUpload reports whether the request carries CSV, either as the body
+// or as a multipart upload.
+func isCSVUpload(c *gin.Context) bool {
+	mediaType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	switch mediaType {
+	case "multipart/form-data", "text/csv", "application/csv":
+		return true
+	}
+	return false
+}
+
+// openCSVUpload returns the CSV sent in the "file" field of a multipart
+// upload, or the request body itself.
+func openCSVUpload(c *gin.Context) (io.ReadCloser, error) {
+	mediaType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		return c.Request.Body, nil
+	}
+	file, _, err := c.Request.FormFile("file")
+	if err != nil {
+		return nil, errors.New("missing CSV upload in the file field")
+	}
+	return file, nil
+}
+
+// parseBulkCSV reads URLRequests from CSV with a header row. Malformed
+// timestamps or numbers fail the whole upload with the offending line,
+// since they usually mean the columns are mislabelled.
+func parseBulkCSV(r io.Reader) ([]models.URLRequest, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, errors.New("CSV must start with a header row")
+	}
+	cols := csvColumns(header, bulkColumns)
+	if _, ok := cols["original_url"]; !ok {
+		return nil, errors.New("CSV header must include original_url")
+	}
+
+	var inputs []models.URLRequest
+	for line := 2; ; line++ {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("CSV line %d: %v", line, err)
+		}
+		if len(inputs) == bulkMaxRows {
+			return nil, fmt.Errorf("at most %d links per request", bulkMaxRows)
+		}
+
+		get := func(name string) string { return csvField(rec, cols, name) }
+		in := models.URLRequest{
+			OriginalURL: get("original_url"),
+			Slug:        get("slug"),
+			FallbackURL: get("fallback_url"),
+			FolderID:    get("folder_id"),
+			Tags:        splitCSVTags(get("tags")),
+		}
+		utm := models.UTM{
+			Source:   get("utm_source"),
+			Medium:   get("utm_medium"),
+			Campaign: get("utm_campaign"),
+			Term:     get("utm_term"),
+			Content:  get("utm_content"),
+		}
+		if utm != (models.UTM{}) {
+			in.UTM = &utm
+		}
+		if in.ExpiresAt, err = parseCSVTime(get("expires_at")); err != nil {
+			return nil, fmt.Errorf("CSV line %d: expires_at: %v", line, err)
+		}
+		if in.ActiveFrom, err = parseCSVTime(get("active_from")); err != nil {
+			return nil, fmt.Errorf("CSV line %d: active_from: %v", line, err)
+		}
+		if v := get("max_clicks"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, fmt.Errorf("CSV line %d: max_clicks must be a number", line)
+			}
+			in.MaxClicks = &n
+		}
+		inputs = append(inputs, in)
+	}
+	return inputs, nil
+}
+
+// csvColumns maps canonical column names to their index in header, using
+// the first alias found. Header names are matched case-insensitively, with
+// spaces treated as underscores ("Long URL" matches long_url).
+func csvColumns(header []string, aliases map[string][]string) map[string]int {
+	index := make(map[string]int, len(header))
+	for i, h := range header {
+		h = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))
+		h = strings.ReplaceAll(h, " ", "_")
+		if _, dup := index[h]; !dup {
+			index[h] = i
+		}
+	}
+	cols := make(map[string]int, len(aliases))
+	for name, names := range aliases {
+		for _, alias := range names {
+			if i, ok := index[alias]; ok {
+				cols[name] = i
+				break
+			}
+		}
+	}
+	return cols
+}
+
+// csvField returns the trimmed value of column name in rec, or "".
+func csvField(rec []string, cols map[string]int, name string) string {
+	i, ok := cols[name]
+	if !ok || i >= len(rec) {
+		return ""
+	}
+	return strings.TrimSpace(rec[i])
+}
+
+// splitCSVTags splits a tags field on commas and semicolons.
+func splitCSVTags(v string) []string {
+	return strings.FieldsFunc(v, func(r rune) bool { return r == ',' || r == ';' })
+}
+
+// parseCSVTime parses an optional RFC 3339 timestamp.
+func parseCSVTime(v string) (*time.Time, error) {
+	if v == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return nil, errors.New("must be an RFC 3339 timestamp")
+	}
+	return &t, nil
+}
+
+// validateBulkRows checks each input like ShortenURL does, records errors
+// in results and returns the rows that may be created. Custom slugs used by
+// more than one row are rejected after the first.
+func validateBulkRows(inputs []models.URLRequest, results []bulkResult, now time.Time) []bulkRow {
+	rows := make([]bulkRow, 0, len(inputs))
+	seen := make(map[string]bool)
+	for i, in := range inputs {
+		fail := func(msg string) {
+			results[i] = bulkResult{Row: i + 1, Status: "error", Error: msg}
+		}
+		if strings.TrimSpace(in.OriginalURL) == "" {
+			fail("original_url is required")
+			continue
+		}
+		if in.Password != "" {
+			fail("password-protected links cannot be created in bulk")
+			continue
+		}
+		if err := validateLinkSettings(&in, now); err != nil {
+			fail(err.Error())
+			continue
+		}
+		tags, err := collections.NormalizeTagNames(in.Tags)
+		if err != nil {
+			fail(err.Error())
+			continue
+		}
+		in.Tags = tags
+		if in.GeoRules, err = normalizeGeoRules(in.GeoRules); err != nil {
+			fail(err.Error())
+			continue
+		}
+		if in.DeviceRules, err = normalizeDeviceRules(in.DeviceRules); err != nil {
+			fail(err.Error())
+			continue
+		}
+		if in.Variants, err = normalizeVariants(in.Variants); err != nil {
+			fail(err.Error())
+			continue
+		}
+		if err := normalizeQuerySettings(&in); err != nil {
+			fail(err.Error())
+			continue
+		}
+		if in.Slug != "" {
+			if err := utils.ValidateSlug(in.Slug); err != nil {
+				fail(err.Error())
+				continue
+			}
+			if seen[in.Slug] {
+				fail("slug is used by an earlier row")
+				continue
+			}
+			seen[in.Slug] = true
+		}
+		rows = append(rows, bulkRow{
+			index:     i,
+			input:     in,
+			custom:    in.Slug != "",
+			id:        uuid.NewString(),
+			slug:      in.Slug,
+			createdAt: now,
+		})
+	}
+	return rows
+}
+
+// checkBulkFolders marks rows filed in a folder the user does not own as
+// errors and returns the remaining rows.
+func checkBulkFolders(db *sql.DB, userID string, rows []bulkRow, results []bulkResult) ([]bulkRow, error) {
+	var ids []string
+	for _, r := range rows {
+		if r.input.FolderID != "" {
+			ids = append(ids, r.input.FolderID)
+		}
+	}
+	owned, err := collections.OwnedFolders(db, userID, ids)
+	if err != nil {
+		return nil, err
+	}
+	kept := rows[:0]
+	for _, r := range rows {
+		if r.input.FolderID != "" && !owned[r.input.FolderID] {
+			results[r.index] = bulkResult{Row: r.index + 1, Status: "error", Error: "folder not found"}
+			continue
+		}
+		kept = append(kept, r)
+	}
+	return kept, nil
+}
+
+// assignBulkSlugs checks all custom slugs with one query, marks taken ones
+// as errors, and generates random slugs for the remaining rows, retrying
+// the ones that collide. It returns the rows that still may be created.
+func assignBulkSlugs(db *sql.DB, rows []bulkRow, results []bulkResult) ([]bulkRow, error) {
+	var custom []string
+	for _, r := range rows {
+		if r.custom {
+			custom = append(custom, r.slug)
+		}
+	}
+	taken, err := takenSlugs(db, custom)
+	if err != nil {
+		return nil, err
+	}
+
+	kept := rows[:0]
+	for _, r := range rows {
+		if r.custom && taken[r.slug] {
+			results[r.index] = bulkResult{Row: r.index + 1, Status: "error", Error: "custom slug already in use"}
+			continue
+		}
+		kept = append(kept, r)
+	}
+	rows = kept
+
+	used := make(map[string]bool, len(rows))
+	for _, r := range rows {
+		if r.custom {
+			used[r.slug] = true
+		}
+	}
+	for attempt := 0; attempt < bulkSlugAttempts; attempt++ {
+		var pending []int
+		var candidates []string
+		for i := range rows {
+			if rows[i].slug != "" {
+				continue
+			}
+			slug, err := utils.GenerateRandomSlug(8)
+			if err != nil {
+				return nil, err
+			}
+			if used[slug] {
+				continue
+			}
+			used[slug] = true
+			rows[i].slug = slug
+			pending = append(pending, i)
+			candidates = append(candidates, slug)
+		}
+		if len(pending) == 0 {
+			break
+		}
+		taken, err := takenSlugs(db, candidates)
+		if err != nil {
+			return nil, err
+		}
+		for _, i := range pending {
+			if taken[rows[i].slug] {
+				rows[i].slug = ""
+			}
+		}
+	}
+
+	kept = rows[:0]
+	for _, r := range rows {
+		if r.slug == "" {
+			results[r.index] = bulkResult{Row: r.index + 1, Status: "error", Error: "failed to generate a unique slug"}
+			continue
+		}
+		kept = append(kept, r)
+	}
+	return kept, nil
+}
+
+// takenSlugs returns which of slugs exist in the urls table or as public
+// links in Redis.
+func takenSlugs(db *sql.DB, slugs []string) (map[string]bool, error) {
+	taken := make(map[string]bool)
+	if len(slugs) == 0 {
+		return taken, nil
+	}
+
+	rows, err := db.Query(`SELECT slug FROM urls WHERE slug = ANY($1)`, pq.Array(slugs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return nil, err
+		}
+		taken[s] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	pipe := storage.RedisClient.Pipeline()
+	cmds := make([]*redis.IntCmd, len(slugs))
+	for i, s := range slugs {
+		cmds[i] = pipe.Exists(storage.Ctx, "slug:"+s)
+	}
+	if _, err := pipe.Exec(storage.Ctx); err != nil {
+		return nil, err
+	}
+	for i, cmd := range cmds {
+		if cmd.Val() > 0 {
+			taken[slugs[i]] = true
+		}
+	}
+	return taken, nil
+}
+
+// insertBulkRows inserts rows and their tags in one transaction, in
+// chunks. Slugs taken concurrently since assignBulkSlugs are skipped; the
+// returned set holds the slugs that were inserted.
+func insertBulkRows(db *sql.DB, userID string, rows []bulkRow) (map[string]bool, error) {
+	created := make(map[string]bool, len(rows))
+	if len(rows) == 0 {
+		return created, nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	for start := 0; start < len(rows); start += bulkInsertChunk {
+		end := start + bulkInsertChunk
+		if end > len(rows) {
+			end = len(rows)
+		}
+
+		var sb strings.Builder
+		sb.WriteString(`INSERT INTO urls (id, user_id, original_url, slug, custom_slug, created_at, click_count, expires_at, max_clicks, active_from, fallback_url, folder_id, geo_rules, device_rules, variants, sticky_variants, forward_query, query_conflict) VALUES `)
+		args := make([]interface{}, 0, (end-start)*18)
+		for i, r := range rows[start:end] {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			n := i * 18
+			fmt.Fprintf(&sb, "($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, NULLIF($%d, ''), NULLIF($%d, ''), $%d, $%d, $%d, $%d, $%d, $%d)",
+				n+1, n+2, n+3, n+4, n+5, n+6, n+7, n+8, n+9, n+10, n+11, n+12, n+13, n+14, n+15, n+16, n+17, n+18)
+			args = append(args, r.id, userID, r.input.OriginalURL, r.slug, r.custom, r.createdAt, r.clicks,
+				r.input.ExpiresAt, r.input.MaxClicks, r.input.ActiveFrom, r.input.FallbackURL, r.input.FolderID,
+				jsonbValue(r.input.GeoRules, len(r.input.GeoRules)),
+				jsonbValue(r.input.DeviceRules, len(r.input.DeviceRules)),
+				jsonbValue(r.input.Variants, len(r.input.Variants)), r.input.StickyVariants,
+				r.input.ForwardQuery, r.input.QueryConflict)
+		}
+		sb.WriteString(` ON CONFLICT (slug) DO NOTHING RETURNING slug`)
+
+		res, err := tx.Query(sb.String(), args...)
+		if err != nil {
+			return nil, err
+		}
+		for res.Next() {
+			var s string
+			if err := res.Scan(&s); err != nil {
+				res.Close()
+				return nil, err
+			}
+			created[s] = true
+		}
+		res.Close()
+		if err := res.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	var links, tags []string
+	for _, r := range rows {
+		if !created[r.slug] {
+			continue
+		}
+		for _, t := range r.input.Tags {
+			links = append(links, r.id)
+			tags = append(tags, t)
+		}
+	}
+	if err := collections.AddLinkTags(tx, userID, links, tags); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// slugCacheFor builds the Redis entry for a newly created link.
+func slugCacheFor(input models.URLRequest, id, userID string) SlugCache {
+	entry := SlugCache{
+		URL:         utils.EnsureProtocol(input.OriginalURL),
+		ID:          id,
+		UserID:      userID,
+		ExpiresAt:   input.ExpiresAt,
+		ActiveFrom:  input.ActiveFrom,
+		FallbackURL: input.FallbackURL,
+		Protected:   input.Password != "",
+		GeoRules:    input.GeoRules,
+		DeviceRules: input.DeviceRules,
+		Variants:    input.Variants,
+		// Stickiness only matters with variants to choose from.
+		StickyVariants: input.StickyVariants && len(input.Variants) > 0,
+		ForwardQuery:   input.ForwardQuery,
+		QueryConflict:  input.QueryConflict,
+	}
+	if input.MaxClicks != nil {
+		entry.MaxClicks = *input.MaxClicks
+	}
+	return entry
+}
+
+// cacheBulkSlugs writes the slug entries in one pipeline. Links are already
+// committed, so failures are logged; RedirectURL falls back to Postgres.
+func cacheBulkSlugs(entries []SlugCache, slugs []string) {
+	if len(entries) == 0 {
+		return
+	}
+	pipe := storage.RedisClient.Pipeline()
+	for i, entry := range entries {
+		seedClickCounter(entry, 0)
+		jsonVal, _ := json.Marshal(entry)
+		pipe.Set(storage.Ctx, "slug:"+slugs[i], jsonVal, cacheTTL(entry, 24*time.Hour))
+	}
+	if _, err := pipe.Exec(storage.Ctx); err != nil {
+		log.Printf("bulk shorten: failed to cache slugs: %v", err)
+	}
+}
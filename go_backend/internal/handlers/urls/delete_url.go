@@ -1,10 +1,10 @@
 package urls
 
 import (
+	"go_backend/internal/authz"
 	"go_backend/internal/storage"
 	"log"
 	"net/http"
-	"os"
 
 	"github.com/gin-gonic/gin"
 )
@@ -13,14 +13,15 @@ import (
 //
 // It performs the following steps:
 //   1. Validates the request body for a required `id` field.
-//   2. Fetches the corresponding slug from the PostgreSQL database.
+//   2. Verifies the caller owns the shortlink and fetches its slug.
 //   3. Deletes the shortlink from the database.
 //   4. Deletes any associated QR code data:
 //      - Redis cache
 //      - Database entry
-//      - Image file in public storage
-//   5. Deletes the slug from Redis cache.
+//      - Image files in public storage
+//   5. Deletes the slug and its click counter from Redis cache.
 //
+// Links owned by other users are reported as not found.
 // Returns appropriate HTTP status codes and JSON messages depending on success or failure.
 // Error messages returned to clients start with lowercase letters as per Go style.
 func DeleteShortlink(c *gin.Context) {
@@ -33,18 +34,19 @@ func DeleteShortlink(c *gin.Context) {
 		return
 	}
 
+	userID, _ := authz.UserID(c)
 	db := storage.GetPostgres()
 
-	// Step 1: Fetch slug from database for Redis cleanup
-	var slug string
-	err := db.QueryRow(`SELECT slug FROM urls WHERE id = $1`, req.ID).Scan(&slug)
+	// Step 1: Verify ownership and fetch slug for Redis cleanup
+	link, err := authz.OwnedLink(db, req.ID, userID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "shortlink not found"})
+		authz.AbortWithLinkError(c, err)
 		return
 	}
+	slug := link.Slug
 
 	// Step 2: Delete shortlink from database
-	_, err = db.Exec(`DELETE FROM urls WHERE id = $1`, req.ID)
+	_, err = db.Exec(`DELETE FROM urls WHERE id = $1 AND user_id = $2`, req.ID, userID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete shortlink"})
 		return
@@ -57,19 +59,16 @@ func DeleteShortlink(c *gin.Context) {
 		log.Printf("failed to delete QR code entry for ID %s: %v", req.ID, err)
 	} else {
 		// 3b. Delete QR code Redis cache
-		if err := storage.RedisClient.Del(storage.Ctx, "qr:"+req.ID).Err(); err != nil {
+		if err := storage.RedisClient.Del(storage.Ctx, qrKeyPrefix+req.ID).Err(); err != nil {
 			log.Printf("failed to delete QR Redis cache for ID %s: %v", req.ID, err)
 		}
 
-		// 3c. Delete QR code image file if exists
-		path := "public/qrcodes/" + req.ID + ".png"
-		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
-			log.Printf("failed to delete QR code image file for ID %s: %v", req.ID, err)
-		}
+		// 3c. Delete QR code image files if they exist
+		removeQRCodeFiles(req.ID)
 	}
 
-	// Step 4: Delete slug from Redis cache
-	if err := storage.RedisClient.Del(storage.Ctx, "slug:"+slug).Err(); err != nil {
+	// Step 4: Delete slug and click counter from Redis cache
+	if err := storage.RedisClient.Del(storage.Ctx, "slug:"+slug, clicksKeyPrefix+req.ID).Err(); err != nil {
 		// Return OK because the main deletion succeeded; include Redis error info
 		c.JSON(http.StatusOK, gin.H{
 			"message":     "shortlink deleted, but redis cleanup failed",
@@ -0,0 +1,94 @@
+package urls
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"go_backend/internal/models"
+	"go_backend/internal/utils"
+)
+
+// maxDeviceRules caps the device rules of one link. Rules only combine four
+// device classes with the known operating systems and browsers, so a few
+// cover any sensible split.
+const maxDeviceRules = 20
+
+// deviceClasses are the device values ParseUserAgent reports.
+var deviceClasses = map[string]bool{"desktop": true, "mobile": true, "tablet": true, "bot": true}
+
+// normalizeDeviceRules validates device rules and rewrites their devices,
+// operating systems and browsers to the names ParseUserAgent reports.
+func normalizeDeviceRules(rules []models.DeviceRule) ([]models.DeviceRule, error) {
+	if len(rules) > maxDeviceRules {
+		return nil, fmt.Errorf("at most %d device_rules per link", maxDeviceRules)
+	}
+	out := make([]models.DeviceRule, len(rules))
+	for i, r := range rules {
+		if len(r.Devices) == 0 && len(r.OS) == 0 && len(r.Browsers) == 0 && r.Bot == nil {
+			return nil, fmt.Errorf("device_rules[%d]: at least one of devices, os, browsers or bot is required", i)
+		}
+		n := models.DeviceRule{Bot: r.Bot, URL: r.URL}
+		for _, d := range r.Devices {
+			d = strings.ToLower(strings.TrimSpace(d))
+			if !deviceClasses[d] {
+				return nil, fmt.Errorf("device_rules[%d]: unknown device %q", i, d)
+			}
+			n.Devices = append(n.Devices, d)
+		}
+		for _, name := range r.OS {
+			osName, ok := utils.CanonicalOS(name)
+			if !ok {
+				return nil, fmt.Errorf("device_rules[%d]: unknown os %q", i, name)
+			}
+			n.OS = append(n.OS, osName)
+		}
+		for _, name := range r.Browsers {
+			browser, ok := utils.CanonicalBrowser(name)
+			if !ok {
+				return nil, fmt.Errorf("device_rules[%d]: unknown browser %q", i, name)
+			}
+			n.Browsers = append(n.Browsers, browser)
+		}
+		if u, err := url.Parse(utils.EnsureProtocol(r.URL)); err != nil || u.Host == "" {
+			return nil, fmt.Errorf("device_rules[%d]: url must be an absolute URL", i)
+		}
+		out[i] = n
+	}
+	return out, nil
+}
+
+// deviceDestination returns the URL of the first device rule matching the
+// visitor's User-Agent, if any.
+func deviceDestination(entry SlugCache, userAgent string) (string, bool) {
+	info := utils.ParseUserAgent(userAgent)
+	for _, r := range entry.DeviceRules {
+		if deviceRuleMatches(r, info) {
+			return utils.EnsureProtocol(r.URL), true
+		}
+	}
+	return "", false
+}
+
+// deviceRuleMatches reports whether info satisfies every condition of r.
+// A condition listing several values matches any of them.
+func deviceRuleMatches(r models.DeviceRule, info utils.UserAgentInfo) bool {
+	if r.Bot != nil && *r.Bot != (info.Device == "bot") {
+		return false
+	}
+	return matchesAny(r.Devices, info.Device) && matchesAny(r.OS, info.OS) && matchesAny(r.Browsers, info.Browser)
+}
+
+// matchesAny reports whether value is in values; an empty list matches
+// everything.
+func matchesAny(values []string, value string) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
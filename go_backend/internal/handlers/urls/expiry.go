@@ -0,0 +1,146 @@
+package urls
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"go_backend/internal/models"
+	"go_backend/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+const clicksKeyPrefix = "clicks:"
+
+// linkState describes whether a link may currently redirect.
+type linkState int
+
+const (
+	linkActive linkState = iota
+	linkPending
+	linkExpired
+	linkExhausted
+)
+
+// validateLinkSettings checks the optional expiry, click limit, activation,
+// fallback and password fields of a create request. It converts expires_at
+// and active_from to UTC first, see utcTime.
+func validateLinkSettings(input *models.URLRequest, now time.Time) error {
+	input.ExpiresAt, input.ActiveFrom = utcTime(input.ExpiresAt), utcTime(input.ActiveFrom)
+	if input.ExpiresAt != nil && !input.ExpiresAt.After(now) {
+		return errors.New("expires_at must be in the future")
+	}
+	if input.ActiveFrom != nil && input.ExpiresAt != nil && !input.ActiveFrom.Before(*input.ExpiresAt) {
+		return errors.New("active_from must be before expires_at")
+	}
+	if input.MaxClicks != nil && *input.MaxClicks < 1 {
+		return errors.New("max_clicks must be at least 1")
+	}
+	if input.FallbackURL != "" {
+		if u, err := url.Parse(input.FallbackURL); err != nil || u.Host == "" {
+			return errors.New("fallback_url must be an absolute URL")
+		}
+	}
+	if input.Password != "" {
+		return validateLinkPassword(input.Password)
+	}
+	return nil
+}
+
+// utcTime returns t converted to UTC, or nil. Link times are stored in
+// TIMESTAMP columns, which drop the client's UTC offset and are read back
+// as UTC, so they must be converted before they are stored or cached.
+func utcTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	u := t.UTC()
+	return &u
+}
+
+// cacheTTL returns the Redis TTL for a slug entry: the default TTL, shortened
+// so that the entry never outlives the link's expiry.
+func cacheTTL(entry SlugCache, def time.Duration) time.Duration {
+	if entry.ExpiresAt == nil {
+		return def
+	}
+	if until := time.Until(*entry.ExpiresAt); until < def {
+		return until
+	}
+	return def
+}
+
+// seedClickCounter initializes the Redis click counter used to enforce
+// max_clicks without a database round trip. Existing counters are kept.
+func seedClickCounter(entry SlugCache, clicks int) {
+	if entry.MaxClicks <= 0 {
+		return
+	}
+	var ttl time.Duration
+	if entry.ExpiresAt != nil {
+		ttl = time.Until(*entry.ExpiresAt)
+	}
+	key := clicksKeyPrefix + entry.ID
+	if err := storage.RedisClient.SetNX(storage.Ctx, key, clicks, ttl).Err(); err != nil {
+		log.Printf("failed to seed click counter for %s: %v", entry.ID, err)
+	}
+}
+
+// checkLinkState evaluates activation and expiry times and, for links with a
+// click limit, the clicks so far. With count set, this redirect is counted
+// against the limit; otherwise the limit is only checked, which lets a
+// redirect be refused for other reasons without using up a click. Redis
+// errors fail open.
+func checkLinkState(entry SlugCache, now time.Time, count bool) linkState {
+	if entry.ActiveFrom != nil && now.Before(*entry.ActiveFrom) {
+		return linkPending
+	}
+	if entry.ExpiresAt != nil && !now.Before(*entry.ExpiresAt) {
+		return linkExpired
+	}
+	if entry.MaxClicks > 0 {
+		key := clicksKeyPrefix + entry.ID
+		if count {
+			n, err := storage.RedisClient.Incr(storage.Ctx, key).Result()
+			if err == nil && n > int64(entry.MaxClicks) {
+				return linkExhausted
+			}
+		} else {
+			n, err := storage.RedisClient.Get(storage.Ctx, key).Int64()
+			if err == nil && n >= int64(entry.MaxClicks) {
+				return linkExhausted
+			}
+		}
+	}
+	return linkActive
+}
+
+// respondUnavailable answers a redirect for a link that is not active. Links
+// not yet active are reported as missing; expired or exhausted links redirect
+// to their fallback URL (or LINK_EXPIRED_REDIRECT_URL) when configured and
+// otherwise return 410 Gone.
+func respondUnavailable(c *gin.Context, entry SlugCache, state linkState) {
+	if state == linkPending {
+		c.JSON(http.StatusNotFound, gin.H{"error": "URL not found"})
+		return
+	}
+
+	fallback := entry.FallbackURL
+	if fallback == "" {
+		fallback = os.Getenv("LINK_EXPIRED_REDIRECT_URL")
+	}
+	if fallback != "" {
+		c.Redirect(http.StatusFound, fallback)
+		return
+	}
+
+	body := gin.H{"error": "link has expired"}
+	if state == linkExhausted {
+		body = gin.H{"error": "link has reached its click limit", "max_clicks": entry.MaxClicks}
+	}
+	c.JSON(http.StatusGone, body)
+}
@@ -0,0 +1,34 @@
+package urls
+
+import (
+	"testing"
+	"time"
+
+	"go_backend/internal/models"
+)
+
+// TestLinkTimesStoredInUTC checks that expires_at and active_from lose
+// their client offset before they reach the TIMESTAMP columns.
+func TestLinkTimesStoredInUTC(t *testing.T) {
+	ist := time.FixedZone("IST", 5*3600+1800)
+	activeFrom := time.Date(2030, 1, 1, 9, 0, 0, 0, ist)
+	expiresAt := time.Date(2030, 1, 2, 9, 0, 0, 0, ist)
+	check := func(name string, got *time.Time, want time.Time) {
+		t.Helper()
+		if got == nil || got.Location() != time.UTC || !got.Equal(want) {
+			t.Errorf("%s = %v, want %v in UTC", name, got, want)
+		}
+	}
+
+	input := models.URLRequest{ActiveFrom: &activeFrom, ExpiresAt: &expiresAt}
+	if err := validateLinkSettings(&input, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	check("created active_from", input.ActiveFrom, activeFrom)
+	check("created expires_at", input.ExpiresAt, expiresAt)
+
+	var link models.URLRequest
+	applyLinkUpdate(&link, models.URLUpdateRequest{ActiveFrom: &activeFrom, ExpiresAt: &expiresAt}, nil)
+	check("updated active_from", link.ActiveFrom, activeFrom)
+	check("updated expires_at", link.ExpiresAt, expiresAt)
+}
@@ -0,0 +1,61 @@
+package urls
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"go_backend/internal/geo"
+	"go_backend/internal/models"
+	"go_backend/internal/utils"
+)
+
+// maxGeoRules caps the geo rules of one link. geoDestination scans all of
+// them for the visitor's country, so the cap bounds that scan on a redirect.
+const maxGeoRules = 50
+
+// normalizeGeoRules validates geo rules and upper-cases their country codes.
+func normalizeGeoRules(rules []models.GeoRule) ([]models.GeoRule, error) {
+	if len(rules) > maxGeoRules {
+		return nil, fmt.Errorf("at most %d geo_rules per link", maxGeoRules)
+	}
+	out := make([]models.GeoRule, len(rules))
+	for i, r := range rules {
+		if len(r.Countries) == 0 {
+			return nil, fmt.Errorf("geo_rules[%d]: countries is required", i)
+		}
+		countries := make([]string, len(r.Countries))
+		for j, cc := range r.Countries {
+			cc = strings.ToUpper(strings.TrimSpace(cc))
+			if len(cc) != 2 || cc[0] < 'A' || cc[0] > 'Z' || cc[1] < 'A' || cc[1] > 'Z' {
+				return nil, fmt.Errorf("geo_rules[%d]: %q is not an ISO 3166-1 alpha-2 country code", i, r.Countries[j])
+			}
+			countries[j] = cc
+		}
+		if u, err := url.Parse(utils.EnsureProtocol(r.URL)); err != nil || u.Host == "" {
+			return nil, fmt.Errorf("geo_rules[%d]: url must be an absolute URL", i)
+		}
+		out[i] = models.GeoRule{Countries: countries, URL: r.URL}
+	}
+	return out, nil
+}
+
+// geoDestination returns the URL of the first geo rule matching the
+// visitor's country, if any. Visitors of unknown country match no rule.
+func geoDestination(entry SlugCache, clientIP string) (string, bool) {
+	if len(entry.GeoRules) == 0 {
+		return "", false
+	}
+	country := geo.LookupString(clientIP).Country
+	if country == "" {
+		return "", false
+	}
+	for _, r := range entry.GeoRules {
+		for _, cc := range r.Countries {
+			if cc == country {
+				return utils.EnsureProtocol(r.URL), true
+			}
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,93 @@
+package urls
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	"go_backend/internal/geo"
+	"go_backend/internal/models"
+)
+
+func TestNormalizeGeoRules(t *testing.T) {
+	tooMany := make([]models.GeoRule, maxGeoRules+1)
+	for i := range tooMany {
+		tooMany[i] = models.GeoRule{Countries: []string{"US"}, URL: "https://example.com"}
+	}
+
+	tests := []struct {
+		name    string
+		rules   []models.GeoRule
+		want    []models.GeoRule
+		wantErr bool
+	}{
+		{name: "none", rules: nil, want: []models.GeoRule{}},
+		{
+			name:  "codes upper-cased",
+			rules: []models.GeoRule{{Countries: []string{" de", "At"}, URL: "https://example.de"}},
+			want:  []models.GeoRule{{Countries: []string{"DE", "AT"}, URL: "https://example.de"}},
+		},
+		{
+			name:  "url without scheme",
+			rules: []models.GeoRule{{Countries: []string{"FR"}, URL: "example.fr/promo"}},
+			want:  []models.GeoRule{{Countries: []string{"FR"}, URL: "example.fr/promo"}},
+		},
+		{name: "no countries", rules: []models.GeoRule{{URL: "https://example.com"}}, wantErr: true},
+		{name: "three letter code", rules: []models.GeoRule{{Countries: []string{"DEU"}, URL: "https://example.com"}}, wantErr: true},
+		{name: "digits", rules: []models.GeoRule{{Countries: []string{"1A"}, URL: "https://example.com"}}, wantErr: true},
+		{name: "no url", rules: []models.GeoRule{{Countries: []string{"US"}}}, wantErr: true},
+		{name: "bad url", rules: []models.GeoRule{{Countries: []string{"US"}, URL: "http://%zz"}}, wantErr: true},
+		{name: "too many", rules: tooMany, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := normalizeGeoRules(tt.rules)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("normalizeGeoRules() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("normalizeGeoRules() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// countryResolver resolves addresses by their string form.
+type countryResolver map[string]string
+
+func (r countryResolver) Lookup(ip net.IP) (geo.Location, error) {
+	return geo.Location{Country: r[ip.String()]}, nil
+}
+
+func TestGeoDestination(t *testing.T) {
+	prev := geo.Default()
+	geo.SetDefault(countryResolver{"192.0.2.1": "DE", "192.0.2.2": "US", "192.0.2.3": "JP"})
+	t.Cleanup(func() { geo.SetDefault(prev) })
+
+	entry := SlugCache{GeoRules: []models.GeoRule{
+		{Countries: []string{"DE", "AT"}, URL: "example.de"},
+		{Countries: []string{"US"}, URL: "https://example.com/us"},
+		{Countries: []string{"DE"}, URL: "https://example.com/unreachable"},
+	}}
+	tests := []struct {
+		ip     string
+		want   string
+		wantOK bool
+	}{
+		{ip: "192.0.2.1", want: "https://example.de", wantOK: true},
+		{ip: "192.0.2.2", want: "https://example.com/us", wantOK: true},
+		{ip: "192.0.2.3"},
+		{ip: "192.0.2.4"},
+		{ip: "not an ip"},
+	}
+	for _, tt := range tests {
+		got, ok := geoDestination(entry, tt.ip)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("geoDestination(%q) = %q, %v, want %q, %v", tt.ip, got, ok, tt.want, tt.wantOK)
+		}
+	}
+
+	if _, ok := geoDestination(SlugCache{}, "192.0.2.1"); ok {
+		t.Error("geoDestination() matched a link without geo rules")
+	}
+}
@@ -0,0 +1,323 @@
+package urls
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go_backend/internal/authz"
+	"go_backend/internal/collections"
+	"go_backend/internal/models"
+	"go_backend/internal/plans"
+	"go_backend/internal/storage"
+	"go_backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// importColumns maps the columns of common shortener exports (Bitly,
+// Rebrandly, TinyURL, Short.io, YOURLS and our own export) onto the fields
+// the importer needs. The first alias present in the header wins. Tags are
+// separated by commas or semicolons and created as needed.
+var importColumns = map[string][]string{
+	"slug": {
+		"slug", "custom_slug", "back_half", "slashtag", "alias", "keyword", "path",
+		"short_url", "shortlink", "short_link", "bitlink", "link",
+	},
+	"original_url": {
+		"original_url", "destination", "long_url", "longurl", "originalurl",
+		"destination_url", "target", "url",
+	},
+	"created_at": {"created_at", "created", "createdat", "date_created", "creation_date", "timestamp", "date"},
+	"clicks":     {"clicks", "click_count", "total_clicks", "hits", "visits"},
+	"tags":       {"tags", "labels"},
+}
+
+// importTimeLayouts are tried in order for the created date column.
+var importTimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"01/02/2006 15:04",
+	"01/02/2006",
+}
+
+// Import row statuses.
+const (
+	importReady    = "ready"    // dry run: would be imported
+	importImported = "imported" // created
+	importConflict = "conflict" // slug already in use
+	importError    = "error"    // invalid row
+)
+
+// importResult reports the outcome of one CSV data row.
+type importResult struct {
+	Row         int    `json:"row"`
+	Status      string `json:"status"`
+	Slug        string `json:"slug,omitempty"`
+	OriginalURL string `json:"original_url,omitempty"`
+	ID          string `json:"id,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// ImportLinks imports links from another shortener's CSV export, keeping
+// their slugs, creation dates and click counts. The CSV is sent as the body
+// (text/csv) or as a multipart upload in the "file" field; columns are
+// recognised by name, see importColumns. Short links such as
+// "https://bit.ly/spring-sale" are reduced to their slug.
+//
+// Each row is checked against the slug rules of the urls table, against
+// other rows and against existing slugs; rows that fail are reported as
+// "error" or "conflict" and skipped. With ?dry_run=true nothing is written
+// and plan limits are reported in "plan_error" instead of failing, so the
+// report can be reviewed before importing for real.
+//
+// Example request:
+//
+//	POST /api/user/import?dry_run=true
+//	Content-Type: text/csv
+//
+//	slug,destination,created,clicks
+//	spring-sale,https://example.com/spring,2024-03-01,1520
+//
+// Responses:
+//
+//	200 OK - per-row results in "results"
+//	400 Bad Request - unreadable CSV or missing columns
+//	403 Forbidden - the importable rows exceed a plan limit
+//	500 Internal Server Error - DB failure
+func ImportLinks(c *gin.Context) {
+	userID, ok := authz.UserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	dryRun, _ := strconv.ParseBool(c.Query("dry_run"))
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, bulkMaxBytes)
+	body, err := openCSVUpload(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer body.Close()
+
+	results, rows, err := parseImportCSV(body, time.Now().UTC())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := storage.GetPostgres()
+	slugs := make([]string, len(rows))
+	for i, r := range rows {
+		slugs[i] = r.slug
+	}
+	taken, err := takenSlugs(db, slugs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server error"})
+		return
+	}
+	kept := rows[:0]
+	for _, r := range rows {
+		if taken[r.slug] {
+			results[r.index].Status = importConflict
+			results[r.index].Error = "slug already in use"
+			continue
+		}
+		kept = append(kept, r)
+	}
+	rows = kept
+
+	// Imported slugs are custom slugs and count against that quota too.
+	var planErr error
+	if len(rows) > 0 {
+		limits, err := plans.ForUser(db, userID)
+		if err == nil {
+			err = plans.CheckLinkCreation(db, userID, limits, len(rows), len(rows))
+		}
+		if _, isLimit := plans.AsLimitError(err); err != nil && (!isLimit || !dryRun) {
+			respondPlanError(c, err)
+			return
+		}
+		planErr = err
+	}
+
+	if !dryRun {
+		created, err := insertBulkRows(db, userID, rows)
+		if err != nil {
+			log.Printf("import: insert failed: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database insert failed"})
+			return
+		}
+
+		var cached []SlugCache
+		var cachedSlugs []string
+		for _, r := range rows {
+			res := &results[r.index]
+			if !created[r.slug] {
+				res.Status, res.Error = importConflict, "slug already in use"
+				continue
+			}
+			res.Status, res.ID = importImported, r.id
+			cached = append(cached, slugCacheFor(r.input, r.id, userID))
+			cachedSlugs = append(cachedSlugs, r.slug)
+		}
+		cacheBulkSlugs(cached, cachedSlugs)
+	}
+
+	counts := map[string]int{}
+	for _, r := range results {
+		counts[r.Status]++
+	}
+	resp := gin.H{
+		"dry_run":   dryRun,
+		"total":     len(results),
+		"ready":     counts[importReady],
+		"imported":  counts[importImported],
+		"conflicts": counts[importConflict],
+		"errors":    counts[importError],
+		"results":   results,
+	}
+	if le, ok := plans.AsLimitError(planErr); ok {
+		resp["plan_error"] = le
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// parseImportCSV reads every data row, validates it and returns a result
+// for each row plus the rows that may be imported. Rows start out "ready".
+func parseImportCSV(r io.Reader, now time.Time) ([]importResult, []bulkRow, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, nil, errors.New("CSV must start with a header row")
+	}
+	cols := csvColumns(header, importColumns)
+	if _, ok := cols["slug"]; !ok {
+		return nil, nil, errors.New("CSV header must include a slug column (e.g. slug, slashtag, alias, keyword or link)")
+	}
+	if _, ok := cols["original_url"]; !ok {
+		return nil, nil, errors.New("CSV header must include a destination column (e.g. original_url, destination or long_url)")
+	}
+
+	var (
+		results []importResult
+		rows    []bulkRow
+		seen    = make(map[string]bool)
+	)
+	for line := 2; ; line++ {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("CSV line %d: %v", line, err)
+		}
+		if len(results) == bulkMaxRows {
+			return nil, nil, fmt.Errorf("at most %d links per import", bulkMaxRows)
+		}
+
+		i := len(results)
+		get := func(name string) string { return utils.UnescapeCSVFormula(csvField(rec, cols, name)) }
+		res := importResult{
+			Row:         i + 1,
+			Status:      importReady,
+			Slug:        importSlug(get("slug")),
+			OriginalURL: get("original_url"),
+		}
+		row, err := importRow(res, get("created_at"), get("clicks"), get("tags"), now)
+		switch {
+		case err != nil:
+			res.Status, res.Error = importError, err.Error()
+		case seen[res.Slug]:
+			res.Status, res.Error = importError, "slug is used by an earlier row"
+		default:
+			seen[res.Slug] = true
+			row.index = i
+			rows = append(rows, row)
+		}
+		results = append(results, res)
+	}
+	return results, rows, nil
+}
+
+// importRow validates one row and converts it to a bulkRow.
+func importRow(res importResult, created, clicks, tags string, now time.Time) (bulkRow, error) {
+	if err := utils.ValidateSlug(res.Slug); err != nil {
+		return bulkRow{}, err
+	}
+	if u, err := url.Parse(utils.EnsureProtocol(res.OriginalURL)); res.OriginalURL == "" || err != nil || u.Host == "" {
+		return bulkRow{}, errors.New("destination must be a URL")
+	}
+
+	names, err := collections.NormalizeTagNames(splitCSVTags(tags))
+	if err != nil {
+		return bulkRow{}, err
+	}
+
+	row := bulkRow{
+		input:     models.URLRequest{OriginalURL: res.OriginalURL, Slug: res.Slug, Tags: names, QueryConflict: queryConflictLink},
+		custom:    true,
+		id:        uuid.NewString(),
+		slug:      res.Slug,
+		createdAt: now,
+	}
+	if created != "" {
+		t, err := parseImportTime(created)
+		if err != nil {
+			return bulkRow{}, err
+		}
+		if t.Before(now) {
+			row.createdAt = t
+		}
+	}
+	if clicks != "" {
+		n, err := strconv.ParseInt(strings.ReplaceAll(clicks, ",", ""), 10, 64)
+		if err != nil || n < 0 {
+			return bulkRow{}, errors.New("clicks must be a non-negative number")
+		}
+		row.clicks = n
+	}
+	return row, nil
+}
+
+// importSlug extracts the slug from a value that may be a full short link
+// ("https://bit.ly/abc" or "bit.ly/abc") or a path ("/abc").
+func importSlug(v string) string {
+	v = strings.TrimSpace(v)
+	if strings.Contains(v, "/") {
+		if u, err := url.Parse(utils.EnsureProtocol(strings.TrimPrefix(v, "/"))); err == nil && strings.Contains(v, ".") {
+			v = u.Path
+		}
+		v = strings.Trim(v, "/")
+	}
+	return v
+}
+
+// parseImportTime parses the created date in any of importTimeLayouts, or as
+// Unix seconds.
+func parseImportTime(v string) (time.Time, error) {
+	for _, layout := range importTimeLayouts {
+		if t, err := time.Parse(layout, v); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	if n, err := strconv.ParseInt(v, 10, 64); err == nil && n > 0 {
+		return time.Unix(n, 0).UTC(), nil
+	}
+	return time.Time{}, errors.New("unrecognised created date")
+}
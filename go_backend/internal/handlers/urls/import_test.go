@@ -0,0 +1,44 @@
+package urls
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+	"time"
+
+	"go_backend/internal/utils"
+)
+
+// TestImportExportedLinks feeds the importer a CSV written the way the link
+// export writes it, whose formula-like values carry a leading quote.
+func TestImportExportedLinks(t *testing.T) {
+	links := []struct{ slug, originalURL string }{
+		{"spring-sale", "https://example.com/spring"},
+		{"-launch", "https://example.com/launch"},
+		{"_beta", "https://example.com/beta"},
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"id", "slug", "original_url", "created_at", "click_count"})
+	for _, l := range links {
+		w.Write([]string{
+			"id", utils.EscapeCSVFormula(l.slug), utils.EscapeCSVFormula(l.originalURL),
+			"2024-03-01T00:00:00Z", "10",
+		})
+	}
+	w.Flush()
+
+	results, rows, err := parseImportCSV(&buf, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != len(links) {
+		t.Fatalf("parseImportCSV() = %d importable rows, want %d; results: %+v", len(rows), len(links), results)
+	}
+	for i, l := range links {
+		if rows[i].slug != l.slug || rows[i].input.OriginalURL != l.originalURL {
+			t.Errorf("row %d = %q -> %q, want %q -> %q", i+1, rows[i].slug, rows[i].input.OriginalURL, l.slug, l.originalURL)
+		}
+	}
+}
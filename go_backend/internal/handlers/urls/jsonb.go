@@ -0,0 +1,29 @@
+package urls
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+)
+
+// jsonbValue returns v as a JSONB query argument. Empty settings (n == 0)
+// are stored as NULL.
+func jsonbValue(v interface{}, n int) sql.NullString {
+	if n == 0 {
+		return sql.NullString{}
+	}
+	b, _ := json.Marshal(v)
+	return sql.NullString{String: string(b), Valid: true}
+}
+
+// scanJSONB decodes a JSONB column read as text into dst. NULL leaves dst
+// unchanged.
+func scanJSONB(v sql.NullString, column string, dst interface{}) error {
+	if !v.Valid {
+		return nil
+	}
+	if err := json.Unmarshal([]byte(v.String), dst); err != nil {
+		return fmt.Errorf("invalid %s: %w", column, err)
+	}
+	return nil
+}
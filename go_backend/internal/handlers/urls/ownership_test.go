@@ -0,0 +1,185 @@
+package urls
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go_backend/internal/middleware"
+	"go_backend/internal/storage"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	testLinkID = "link-a"
+	testSlug   = "a-slug"
+	testUserA  = "user-a"
+	testUserB  = "user-b"
+)
+
+// summaryBreakdowns is the number of breakdown queries analytics.Summarize
+// runs for a single link.
+const summaryBreakdowns = 7
+
+const ownedLinkQuery = `SELECT id, user_id, slug FROM urls WHERE id = \$1 AND user_id = \$2`
+
+// setupStores points storage at a sqlmock database and a miniredis server
+// for the duration of the test.
+func setupStores(t *testing.T) (sqlmock.Sqlmock, *miniredis.Miniredis) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	storage.SetPostgres(db)
+
+	mr := miniredis.RunT(t)
+	storage.RedisClient = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { storage.RedisClient.Close() })
+	return mock, mr
+}
+
+// expectOwnedLink mocks the ownership lookup of testLinkID for userID,
+// which succeeds only for testUserA.
+func expectOwnedLink(mock sqlmock.Sqlmock, query, userID string) {
+	rows := sqlmock.NewRows([]string{"id", "user_id", "slug"})
+	if userID == testUserA {
+		rows.AddRow(testLinkID, testUserA, testSlug)
+	}
+	mock.ExpectQuery(query).WithArgs(testLinkID, userID).WillReturnRows(rows)
+}
+
+// newTestRouter registers the link routes behind a stub authentication
+// middleware that signs every request in as userID.
+func newTestRouter(userID string) *gin.Engine {
+	r := gin.New()
+	auth := func(c *gin.Context) {
+		c.Set("userID", userID)
+		c.Next()
+	}
+	r.POST("/api/update/shortlink", auth, UpdateShortlink)
+	r.POST("/api/delete/shortlink", auth, DeleteShortlink)
+	link := r.Group("/api/user/shortlinks/:id", auth, middleware.RequireLinkOwner())
+	link.GET("/analytics", GetLinkAnalytics)
+	link.GET("/qrcode", GetQRCode)
+	return r
+}
+
+func serve(r *gin.Engine, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestLinkRoutesRequireOwner(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		// expect mocks what the handler needs after ownership is verified.
+		expect func(sqlmock.Sqlmock, *miniredis.Miniredis)
+	}{
+		{
+			name:   "analytics",
+			method: http.MethodGet,
+			path:   "/api/user/shortlinks/" + testLinkID + "/analytics",
+			expect: func(mock sqlmock.Sqlmock, _ *miniredis.Miniredis) {
+				mock.ExpectQuery(`SELECT plan FROM users WHERE id = \$1`).
+					WithArgs(testUserA).
+					WillReturnRows(sqlmock.NewRows([]string{"plan"}).AddRow("free"))
+				mock.ExpectQuery(`SELECT COUNT\(\*\), COUNT\(\*\) FILTER \(WHERE is_unique\)`).
+					WillReturnRows(sqlmock.NewRows([]string{"count", "unique"}).AddRow(0, 0))
+				for range summaryBreakdowns {
+					mock.ExpectQuery(`SELECT COALESCE\(NULLIF`).
+						WillReturnRows(sqlmock.NewRows([]string{"value", "clicks"}))
+				}
+			},
+		},
+		{
+			name:   "qrcode",
+			method: http.MethodGet,
+			path:   "/api/user/shortlinks/" + testLinkID + "/qrcode",
+			expect: func(_ sqlmock.Sqlmock, mr *miniredis.Miniredis) {
+				mr.HSet(qrKeyPrefix+testLinkID, "png", "\x89PNG")
+			},
+		},
+		{
+			name:   "delete",
+			method: http.MethodPost,
+			path:   "/api/delete/shortlink",
+			body:   `{"id":"` + testLinkID + `"}`,
+			expect: func(mock sqlmock.Sqlmock, _ *miniredis.Miniredis) {
+				mock.ExpectExec(`DELETE FROM urls WHERE id = \$1 AND user_id = \$2`).
+					WithArgs(testLinkID, testUserA).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(`DELETE FROM qr_codes WHERE id = \$1`).
+					WithArgs(testLinkID).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+		},
+		{
+			name:   "update",
+			method: http.MethodPost,
+			path:   "/api/update/shortlink",
+			body:   `{"id":"` + testLinkID + `","original_url":"https://example.com/new"}`,
+			expect: func(mock sqlmock.Sqlmock, _ *miniredis.Miniredis) {
+				mock.ExpectQuery(`SELECT original_url, expires_at`).
+					WithArgs(testLinkID).
+					WillReturnRows(sqlmock.NewRows([]string{
+						"original_url", "expires_at", "active_from", "max_clicks", "fallback_url", "folder_id",
+						"geo_rules", "device_rules", "variants", "sticky_variants", "forward_query",
+						"query_conflict", "custom_slug",
+					}).AddRow("https://example.com/old", nil, nil, nil, nil, nil, nil, nil, nil, false, false, "link", false))
+				mock.ExpectQuery(`UPDATE urls`).
+					WillReturnRows(sqlmock.NewRows([]string{"protected"}).AddRow(false))
+				mock.ExpectQuery(`FROM url_tags`).
+					WillReturnRows(sqlmock.NewRows([]string{"url_id", "id", "name"}))
+				mock.ExpectCommit()
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		for _, userID := range []string{testUserA, testUserB} {
+			t.Run(tt.name+"/"+userID, func(t *testing.T) {
+				mock, mr := setupStores(t)
+
+				query := ownedLinkQuery
+				if tt.name == "update" {
+					mock.ExpectBegin()
+					query += ` FOR UPDATE`
+				}
+				expectOwnedLink(mock, query, userID)
+
+				want := http.StatusNotFound
+				if userID == testUserA {
+					want = http.StatusOK
+					tt.expect(mock, mr)
+				} else if tt.name == "update" {
+					mock.ExpectRollback()
+				}
+
+				w := serve(newTestRouter(userID), tt.method, tt.path, tt.body)
+				if w.Code != want {
+					t.Fatalf("status = %d, want %d; body: %s", w.Code, want, w.Body)
+				}
+				if err := mock.ExpectationsWereMet(); err != nil {
+					t.Error(err)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package urls
+
+import (
+	"database/sql"
+	"errors"
+	"html/template"
+	"net/http"
+	"strconv"
+
+	"go_backend/internal/models"
+	"go_backend/internal/security"
+	"go_backend/internal/storage"
+	"go_backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Length bounds for link passwords; bcrypt ignores bytes past 72.
+const (
+	minLinkPasswordLength = 4
+	maxLinkPasswordLength = 72
+)
+
+// unlockPage is served to browsers opening a password-protected link.
+var unlockPage = template.Must(template.New("unlock").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Protected link</title>
+<style>
+body{font-family:system-ui,sans-serif;display:flex;min-height:100vh;margin:0;align-items:center;justify-content:center;background:#f5f5f5}
+form{background:#fff;padding:2rem;border-radius:8px;box-shadow:0 1px 4px rgba(0,0,0,.1);width:18rem}
+input,button{box-sizing:border-box;width:100%;padding:.6rem;margin-top:.75rem;font-size:1rem}
+.error{color:#b00020}
+</style>
+</head>
+<body>
+<form method="post" action="/{{.Slug}}/unlock">
+<strong>This link is password protected.</strong>
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+<input type="password" name="password" placeholder="Password" autofocus required>
+<button type="submit">Open link</button>
+</form>
+</body>
+</html>
+`))
+
+// validateLinkPassword checks the length of a new link password.
+func validateLinkPassword(password string) error {
+	if len(password) < minLinkPasswordLength || len(password) > maxLinkPasswordLength {
+		return errors.New("password must be 4-72 characters")
+	}
+	return nil
+}
+
+// hashLinkPassword returns the bcrypt hash of password, or "" for no
+// password.
+func hashLinkPassword(password string) (string, error) {
+	if password == "" {
+		return "", nil
+	}
+	return utils.HashPassword(password)
+}
+
+// linkUnlocked reports whether the request carries a valid unlock cookie
+// for the link.
+func linkUnlocked(c *gin.Context, linkID string) bool {
+	for _, cookie := range c.Request.Cookies() {
+		if cookie.Name == utils.LinkUnlockCookieName && security.ValidLinkUnlockToken(cookie.Value, linkID) {
+			return true
+		}
+	}
+	return false
+}
+
+// respondLocked answers a redirect of a password-protected link that has not
+// been unlocked: browsers get a password form, API clients a JSON challenge
+// pointing at UnlockURL.
+func respondLocked(c *gin.Context, slug string, status int, message string) {
+	c.Header("Cache-Control", "no-store")
+	if c.NegotiateFormat(gin.MIMEJSON, gin.MIMEHTML) == gin.MIMEHTML {
+		c.Status(status)
+		c.Header("Content-Type", "text/html; charset=utf-8")
+		_ = unlockPage.Execute(c.Writer, struct{ Slug, Error string }{slug, message})
+		return
+	}
+	if message == "" {
+		message = "password required"
+	}
+	c.JSON(status, gin.H{
+		"error":      message,
+		"code":       "password_required",
+		"unlock_url": "/" + slug + "/unlock",
+	})
+}
+
+// UnlockURL checks the password of a protected link and sets a short-lived
+// signed cookie (LINK_UNLOCK_TTL_MINUTES) that lets RedirectURL through.
+// The password is sent as JSON or, from the unlock page, as a form field.
+// Guesses are rate limited per slug and client (LINK_UNLOCK_RATE_LIMIT_MAX).
+//
+// Example request:
+//
+//	POST /:slug/unlock
+//	{"password": "s3cret"}
+//
+// Responses:
+//
+//	200 OK - unlocked (JSON); form posts are redirected to the link (303)
+//	400 Bad Request - password missing
+//	401 Unauthorized - wrong password
+//	404 Not Found - no such link, or the link has no password
+//	429 Too Many Requests - too many guesses, see Retry-After
+func UnlockURL(c *gin.Context) {
+	slug := c.Param("slug")
+
+	if ok, retry := security.AllowLinkUnlockAttempt(c.ClientIP(), slug); !ok {
+		c.Header("Retry-After", strconv.Itoa(retry))
+		respondLocked(c, slug, http.StatusTooManyRequests, "too many attempts, try again later")
+		return
+	}
+
+	var input models.LinkUnlockInput
+	if err := c.ShouldBind(&input); err != nil {
+		respondLocked(c, slug, http.StatusBadRequest, "password is required")
+		return
+	}
+
+	var id string
+	var hash sql.NullString
+	err := storage.GetPostgres().QueryRow(`SELECT id, password_hash FROM urls WHERE slug = $1`, slug).Scan(&id, &hash)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !hash.Valid) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "URL not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+		return
+	}
+	if !utils.CheckPasswordHash(input.Password, hash.String) {
+		respondLocked(c, slug, http.StatusUnauthorized, "incorrect password")
+		return
+	}
+
+	token, expires := security.GenerateLinkUnlockToken(id)
+	utils.SetLinkUnlockCookie(c, slug, token, expires)
+
+	if c.ContentType() == gin.MIMEPOSTForm {
+		c.Redirect(http.StatusSeeOther, "/"+slug)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "link unlocked",
+		"expires_at": expires,
+	})
+}
@@ -0,0 +1,44 @@
+package urls
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestUnlockURLLimitsSpoofedClients checks that guesses are counted per
+// client address, however X-Forwarded-For is set by an untrusted client.
+func TestUnlockURLLimitsSpoofedClients(t *testing.T) {
+	setupStores(t)
+	r := gin.New()
+	if err := r.SetTrustedProxies(nil); err != nil {
+		t.Fatal(err)
+	}
+	r.POST("/:slug/unlock", UnlockURL)
+
+	unlock := func(remoteAddr, forwardedFor string) int {
+		req := httptest.NewRequest(http.MethodPost, "/"+testSlug+"/unlock", strings.NewReader(`{}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// Each client may make a fifth of the default ten guesses per slug.
+	for i, xff := range []string{"203.0.113.1", "203.0.113.2"} {
+		if code := unlock("192.0.2.1:1234", xff); code != http.StatusBadRequest {
+			t.Fatalf("attempt %d: status = %d, want %d", i+1, code, http.StatusBadRequest)
+		}
+	}
+	if code := unlock("192.0.2.1:1234", "203.0.113.3"); code != http.StatusTooManyRequests {
+		t.Errorf("spoofed attempt: status = %d, want %d", code, http.StatusTooManyRequests)
+	}
+	if code := unlock("192.0.2.2:1234", "203.0.113.3"); code != http.StatusBadRequest {
+		t.Errorf("other client: status = %d, want %d", code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,296 @@
+package urls
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go_backend/internal/authz"
+	"go_backend/internal/models"
+	"go_backend/internal/plans"
+	"go_backend/internal/qrcode"
+	"go_backend/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	qrCodeDir       = "public/qrcodes"
+	qrKeyPrefix     = "qr:"
+	qrCacheTTL      = 7 * 24 * time.Hour
+	defaultQRSize   = 512
+	minQRSize       = 64
+	maxQRSize       = 2048
+	defaultQRMargin = 4
+	maxQRMargin     = 16
+)
+
+// qrSettings are the validated, persisted rendering options of a QR code.
+type qrSettings struct {
+	Size       int    `json:"size"`
+	Level      string `json:"error_correction"`
+	Margin     int    `json:"margin"`
+	Foreground string `json:"foreground"`
+	Background string `json:"background"`
+}
+
+// qrSettingsFromRequest applies defaults and validates a QRCodeRequest.
+func qrSettingsFromRequest(req models.QRCodeRequest) (qrSettings, error) {
+	s := qrSettings{
+		Size:       req.Size,
+		Level:      req.ErrorCorrection,
+		Margin:     defaultQRMargin,
+		Foreground: req.Foreground,
+		Background: req.Background,
+	}
+	if s.Size == 0 {
+		s.Size = defaultQRSize
+	}
+	if s.Level == "" {
+		s.Level = "M"
+	}
+	if req.Margin != nil {
+		s.Margin = *req.Margin
+	}
+	if s.Foreground == "" {
+		s.Foreground = "#000000"
+	}
+	if s.Background == "" {
+		s.Background = "#ffffff"
+	}
+
+	if s.Size < minQRSize || s.Size > maxQRSize {
+		return s, fmt.Errorf("size must be between %d and %d", minQRSize, maxQRSize)
+	}
+	if s.Margin < 0 || s.Margin > maxQRMargin {
+		return s, fmt.Errorf("margin must be between 0 and %d", maxQRMargin)
+	}
+	level, err := qrcode.ParseLevel(s.Level)
+	if err != nil {
+		return s, errors.New("error_correction must be L, M, Q or H")
+	}
+	s.Level = level.String()
+	if _, err := qrcode.ParseHexColor(s.Foreground); err != nil {
+		return s, errors.New("foreground must be a hex colour such as #000000")
+	}
+	if _, err := qrcode.ParseHexColor(s.Background); err != nil {
+		return s, errors.New("background must be a hex colour such as #ffffff")
+	}
+	return s, nil
+}
+
+// qrAssets holds a rendered QR code in both output formats.
+type qrAssets struct {
+	PNG []byte
+	SVG []byte
+}
+
+// renderQRCode encodes content with the given settings.
+func renderQRCode(content string, s qrSettings) (qrAssets, error) {
+	level, err := qrcode.ParseLevel(s.Level)
+	if err != nil {
+		return qrAssets{}, err
+	}
+	code, err := qrcode.Encode([]byte(content), level)
+	if err != nil {
+		return qrAssets{}, err
+	}
+	fg, _ := qrcode.ParseHexColor(s.Foreground)
+	bg, _ := qrcode.ParseHexColor(s.Background)
+	opts := qrcode.RenderOptions{Size: s.Size, Margin: s.Margin, Foreground: fg, Background: bg}
+
+	pngData, err := code.PNG(opts)
+	if err != nil {
+		return qrAssets{}, err
+	}
+	return qrAssets{PNG: pngData, SVG: code.SVG(opts)}, nil
+}
+
+// storeQRAssets writes the rendered images to public storage and caches
+// them in the qr:<id> Redis hash.
+func storeQRAssets(id string, a qrAssets) {
+	if err := os.MkdirAll(qrCodeDir, 0o755); err != nil {
+		log.Printf("failed to create QR code directory: %v", err)
+	} else {
+		for ext, data := range map[string][]byte{".png": a.PNG, ".svg": a.SVG} {
+			if err := os.WriteFile(filepath.Join(qrCodeDir, id+ext), data, 0o644); err != nil {
+				log.Printf("failed to write QR code image for ID %s: %v", id, err)
+			}
+		}
+	}
+
+	pipe := storage.RedisClient.TxPipeline()
+	pipe.HSet(storage.Ctx, qrKeyPrefix+id, "png", a.PNG, "svg", a.SVG)
+	pipe.Expire(storage.Ctx, qrKeyPrefix+id, qrCacheTTL)
+	if _, err := pipe.Exec(storage.Ctx); err != nil {
+		log.Printf("failed to cache QR code for ID %s: %v", id, err)
+	}
+}
+
+// removeQRCodeFiles deletes the stored QR code images of a link.
+func removeQRCodeFiles(id string) {
+	for _, ext := range []string{".png", ".svg"} {
+		path := filepath.Join(qrCodeDir, id+ext)
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			log.Printf("failed to delete QR code image file for ID %s: %v", id, err)
+		}
+	}
+}
+
+// generateQRCode renders, persists and caches the QR code of a link and
+// marks the link as having one.
+func generateQRCode(db *sql.DB, id, shortURL string, s qrSettings) (qrAssets, error) {
+	assets, err := renderQRCode(shortURL, s)
+	if err != nil {
+		return assets, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return assets, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO qr_codes (id, size, error_correction, margin, foreground, background, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW(), NOW())
+		ON CONFLICT (id) DO UPDATE
+		SET size = EXCLUDED.size, error_correction = EXCLUDED.error_correction, margin = EXCLUDED.margin,
+		    foreground = EXCLUDED.foreground, background = EXCLUDED.background, updated_at = NOW()`,
+		id, s.Size, s.Level, s.Margin, s.Foreground, s.Background)
+	if err != nil {
+		return assets, err
+	}
+	if _, err := tx.Exec(`UPDATE urls SET created_qrcode = TRUE WHERE id = $1`, id); err != nil {
+		return assets, err
+	}
+	if err := tx.Commit(); err != nil {
+		return assets, err
+	}
+
+	storeQRAssets(id, assets)
+	return assets, nil
+}
+
+// CreateQRCode generates (or regenerates) the QR code of a shortlink owned
+// by the caller. Ownership is verified by middleware.RequireLinkOwner.
+//
+// Example request:
+//
+//	POST /api/user/shortlinks/:id/qrcode
+//	{
+//	  "size": 512,
+//	  "error_correction": "Q",
+//	  "margin": 4,
+//	  "foreground": "#1e293b",
+//	  "background": "#ffffff"
+//	}
+//
+// Responses:
+//
+//	200 OK - QR code generated; image URLs returned
+//	400 Bad Request - invalid options
+//	403 Forbidden - plan QR code limit reached
+//	404 Not Found - shortlink does not exist or belongs to another user
+//	500 Internal Server Error - rendering or DB failure
+func CreateQRCode(c *gin.Context) {
+	var req models.QRCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid input"})
+		return
+	}
+	settings, err := qrSettingsFromRequest(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	link, _ := authz.LinkFromContext(c)
+	db := storage.GetPostgres()
+
+	// Regenerating an existing QR code does not count against the quota.
+	var hasQRCode bool
+	err = db.QueryRow(`SELECT COALESCE(created_qrcode, FALSE) FROM urls WHERE id = $1`, link.ID).Scan(&hasQRCode)
+	if err == nil && !hasQRCode {
+		var limits plans.Limits
+		if limits, err = plans.ForUser(db, link.UserID); err == nil {
+			err = plans.CheckQRCode(db, link.UserID, limits)
+		}
+	}
+	if err != nil {
+		respondPlanError(c, err)
+		return
+	}
+
+	shortURL := getBaseURLFromRequest(c) + "/" + link.Slug
+	if _, err := generateQRCode(db, link.ID, shortURL, settings); err != nil {
+		if errors.Is(err, qrcode.ErrSizeTooSmall) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "size too small for this QR code"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate QR code"})
+		return
+	}
+
+	imageURL := getBaseURLFromRequest(c) + "/api/user/shortlinks/" + link.ID + "/qrcode"
+	c.JSON(http.StatusOK, gin.H{
+		"id":        link.ID,
+		"short_url": shortURL,
+		"png_url":   imageURL + "?format=png",
+		"svg_url":   imageURL + "?format=svg",
+		"settings":  settings,
+	})
+}
+
+// GetQRCode serves the QR code of a shortlink owned by the caller as PNG
+// (default) or SVG. Images come from the Redis cache and are re-rendered
+// from the persisted settings on a cache miss.
+//
+// Example request:
+//
+//	GET /api/user/shortlinks/:id/qrcode?format=svg
+func GetQRCode(c *gin.Context) {
+	format := c.DefaultQuery("format", "png")
+	contentType := map[string]string{"png": "image/png", "svg": "image/svg+xml"}[format]
+	if contentType == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be png or svg"})
+		return
+	}
+
+	link, _ := authz.LinkFromContext(c)
+	data, err := storage.RedisClient.HGet(storage.Ctx, qrKeyPrefix+link.ID, format).Bytes()
+	if err != nil || len(data) == 0 {
+		var s qrSettings
+		err := storage.GetPostgres().QueryRow(`
+			SELECT size, error_correction, margin, foreground, background
+			FROM qr_codes WHERE id = $1`, link.ID).
+			Scan(&s.Size, &s.Level, &s.Margin, &s.Foreground, &s.Background)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "QR code not generated"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "server error"})
+			return
+		}
+
+		assets, err := renderQRCode(getBaseURLFromRequest(c)+"/"+link.Slug, s)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to render QR code"})
+			return
+		}
+		storeQRAssets(link.ID, assets)
+		data = assets.PNG
+		if format == "svg" {
+			data = assets.SVG
+		}
+	}
+
+	c.Header("Cache-Control", "private, max-age=300")
+	c.Data(http.StatusOK, contentType, data)
+}
@@ -0,0 +1,149 @@
+package urls
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"go_backend/internal/models"
+)
+
+// Ways to resolve a query parameter present both on the short URL a visitor
+// opened and on the link's destination when forward_query is on.
+const (
+	queryConflictLink    = "link"    // keep the destination's value (default)
+	queryConflictRequest = "request" // replace it with the visitor's value
+	queryConflictAppend  = "append"  // keep both
+)
+
+// maxUTMValueLength caps each UTM field.
+const maxUTMValueLength = 200
+
+// normalizeQueryConflict validates a query_conflict setting; empty selects
+// queryConflictLink.
+func normalizeQueryConflict(mode string) (string, error) {
+	switch mode = strings.ToLower(strings.TrimSpace(mode)); mode {
+	case "":
+		return queryConflictLink, nil
+	case queryConflictLink, queryConflictRequest, queryConflictAppend:
+		return mode, nil
+	}
+	return "", errors.New("query_conflict must be link, request or append")
+}
+
+// normalizeQuerySettings merges the UTM fields of a new link into its
+// original URL and validates its query_conflict setting.
+func normalizeQuerySettings(input *models.URLRequest) error {
+	dest, err := withUTM(input.OriginalURL, input.UTM)
+	if err != nil {
+		return err
+	}
+	mode, err := normalizeQueryConflict(input.QueryConflict)
+	if err != nil {
+		return err
+	}
+	input.OriginalURL, input.UTM, input.QueryConflict = dest, nil, mode
+	return nil
+}
+
+// withUTM returns dest with the non-empty UTM fields set as utm_* query
+// parameters, replacing any the destination already carries.
+func withUTM(dest string, utm *models.UTM) (string, error) {
+	if utm == nil {
+		return dest, nil
+	}
+	fields := []struct{ key, value string }{
+		{"utm_source", utm.Source},
+		{"utm_medium", utm.Medium},
+		{"utm_campaign", utm.Campaign},
+		{"utm_term", utm.Term},
+		{"utm_content", utm.Content},
+	}
+	var pairs []string
+	for _, f := range fields {
+		v := strings.TrimSpace(f.value)
+		if v == "" {
+			continue
+		}
+		if len(v) > maxUTMValueLength {
+			return "", fmt.Errorf("%s must be at most %d characters", f.key, maxUTMValueLength)
+		}
+		pairs = append(pairs, url.QueryEscape(f.key)+"="+url.QueryEscape(v))
+	}
+	return mergeQuery(dest, pairs, queryConflictRequest), nil
+}
+
+// forwardQuery appends the query string of the short URL a visitor opened to
+// dest, resolving parameters present on both according to mode.
+func forwardQuery(dest, rawQuery, mode string) string {
+	if rawQuery == "" {
+		return dest
+	}
+	return mergeQuery(dest, strings.Split(rawQuery, "&"), mode)
+}
+
+// mergeQuery adds the raw key=value pairs to the query string of rawURL.
+// Pairs are kept as they are encoded, so existing parameters and any
+// fragment of rawURL are preserved byte for byte. For keys present on both
+// sides, mode decides which pairs survive; unknown modes act as
+// queryConflictLink.
+func mergeQuery(rawURL string, pairs []string, mode string) string {
+	if len(pairs) == 0 {
+		return rawURL
+	}
+	base, fragment, hasFragment := strings.Cut(rawURL, "#")
+	path, query, _ := strings.Cut(base, "?")
+
+	var existing []string
+	if query != "" {
+		existing = strings.Split(query, "&")
+	}
+	switch mode {
+	case queryConflictAppend:
+	case queryConflictRequest:
+		existing = withoutKeys(existing, pairs)
+	default:
+		pairs = withoutKeys(pairs, existing)
+	}
+
+	merged := make([]string, 0, len(existing)+len(pairs))
+	for _, p := range append(existing, pairs...) {
+		if p != "" {
+			merged = append(merged, p)
+		}
+	}
+
+	out := path
+	if len(merged) > 0 {
+		out += "?" + strings.Join(merged, "&")
+	}
+	if hasFragment {
+		out += "#" + fragment
+	}
+	return out
+}
+
+// withoutKeys returns the pairs whose key does not occur in others.
+func withoutKeys(pairs, others []string) []string {
+	keys := make(map[string]bool, len(others))
+	for _, p := range others {
+		keys[queryKey(p)] = true
+	}
+	kept := pairs[:0:0]
+	for _, p := range pairs {
+		if !keys[queryKey(p)] {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
+
+// queryKey returns the decoded key of a raw key=value pair.
+func queryKey(pair string) string {
+	key, _, _ := strings.Cut(pair, "=")
+	if k, err := url.QueryUnescape(key); err == nil {
+		return k
+	}
+	return key
+}
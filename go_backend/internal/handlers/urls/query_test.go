@@ -0,0 +1,64 @@
+package urls
+
+import (
+	"reflect"
+	"testing"
+
+	"go_backend/internal/models"
+)
+
+func TestForwardQuery(t *testing.T) {
+	const dest = "https://example.com/p?a=1#frag"
+	tests := []struct {
+		name     string
+		dest     string
+		rawQuery string
+		mode     string
+		want     string
+	}{
+		{name: "no query", dest: dest, mode: queryConflictLink, want: dest},
+		{name: "new key", dest: dest, rawQuery: "b=2", mode: queryConflictLink, want: "https://example.com/p?a=1&b=2#frag"},
+		{name: "link keeps destination", dest: dest, rawQuery: "a=2&b=3", mode: queryConflictLink, want: "https://example.com/p?a=1&b=3#frag"},
+		{name: "request replaces destination", dest: dest, rawQuery: "a=2&b=3", mode: queryConflictRequest, want: "https://example.com/p?a=2&b=3#frag"},
+		{name: "append keeps both", dest: dest, rawQuery: "a=2&b=3", mode: queryConflictAppend, want: "https://example.com/p?a=1&a=2&b=3#frag"},
+		{name: "unknown mode acts as link", dest: dest, rawQuery: "a=2", mode: "bogus", want: dest},
+		{name: "encoded keys match", dest: "https://example.com/?utm%5Fsource=x", rawQuery: "utm_source=y", mode: queryConflictRequest, want: "https://example.com/?utm_source=y"},
+		{name: "encoding preserved", dest: "https://example.com/?q=a%20b", rawQuery: "r=c+d", mode: queryConflictLink, want: "https://example.com/?q=a%20b&r=c+d"},
+		{name: "empty pairs dropped", dest: "https://example.com/?a=1&", rawQuery: "&b=2", mode: queryConflictLink, want: "https://example.com/?a=1&b=2"},
+		{name: "destination without query", dest: "https://example.com/p#frag", rawQuery: "b=2", mode: queryConflictLink, want: "https://example.com/p?b=2#frag"},
+		{name: "all removed", dest: "https://example.com/p?a=1", rawQuery: "a=2", mode: queryConflictLink, want: "https://example.com/p?a=1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := forwardQuery(tt.dest, tt.rawQuery, tt.mode); got != tt.want {
+				t.Errorf("forwardQuery(%q, %q, %q) = %q, want %q", tt.dest, tt.rawQuery, tt.mode, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithoutKeys(t *testing.T) {
+	tests := []struct {
+		pairs, others, want []string
+	}{
+		{pairs: []string{"a=1", "b=2"}, others: []string{"a=9"}, want: []string{"b=2"}},
+		{pairs: []string{"a=1", "a=2"}, others: []string{"a"}, want: []string{}},
+		{pairs: []string{"a%5Fb=1"}, others: []string{"a_b=2"}, want: []string{}},
+		{pairs: []string{"a=1"}, others: nil, want: []string{"a=1"}},
+	}
+	for _, tt := range tests {
+		if got := withoutKeys(tt.pairs, tt.others); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("withoutKeys(%q, %q) = %q, want %q", tt.pairs, tt.others, got, tt.want)
+		}
+	}
+}
+
+func TestWithUTM(t *testing.T) {
+	got, err := withUTM("https://example.com/p?utm_source=old&a=1#frag", &models.UTM{Source: "news letter", Medium: " email "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "https://example.com/p?a=1&utm_source=news+letter&utm_medium=email#frag"; got != want {
+		t.Errorf("withUTM() = %q, want %q", got, want)
+	}
+}
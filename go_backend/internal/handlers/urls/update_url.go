@@ -0,0 +1,451 @@
+package urls
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"go_backend/internal/authz"
+	"go_backend/internal/collections"
+	"go_backend/internal/models"
+	"go_backend/internal/plans"
+	"go_backend/internal/storage"
+	"go_backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// staleCacheRecheck is how long after an update the old slug key is deleted
+// a second time, catching redirects that read the pre-update row and
+// re-cached it while the update was committing.
+const staleCacheRecheck = 2 * time.Second
+
+// clearableFields lists the settings a URLUpdateRequest may reset to NULL.
+var clearableFields = map[string]bool{
+	"expires_at":   true,
+	"max_clicks":   true,
+	"active_from":  true,
+	"fallback_url": true,
+	"folder_id":    true,
+	"password":     true,
+	"geo_rules":    true,
+	"device_rules": true,
+	"variants":     true,
+}
+
+// UpdateShortlink changes the destination, slug, expiry, redirect limits,
+// folder, tags, password, geo rules, device rules, A/B variants or query
+// forwarding of a shortlink owned by the caller. tags, geo_rules,
+// device_rules and variants replace the current ones; an empty list removes
+// them all. Visitors who unlocked the link keep access until their unlock
+// cookie expires.
+//
+// Example request:
+//
+//	POST /api/update/shortlink
+//	{
+//	  "id": "6f1c...",
+//	  "original_url": "https://example.com/fixed",
+//	  "slug": "new-slug",
+//	  "clear": ["expires_at"]
+//	}
+//
+// The old and new slug: keys are removed from Redis in one MULTI/EXEC after
+// the database commit, so the next redirect reloads the updated row.
+//
+// Responses:
+//
+//	200 OK - shortlink updated
+//	400 Bad Request - invalid input
+//	403 Forbidden - plan custom slug limit reached
+//	404 Not Found - shortlink does not exist or belongs to another user
+//	409 Conflict - new slug already in use
+//	500 Internal Server Error - DB failure
+func UpdateShortlink(c *gin.Context) {
+	var req models.URLUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.ID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id is required"})
+		return
+	}
+	var tags []string
+	if req.Tags != nil {
+		var err error
+		if tags, err = collections.NormalizeTagNames(*req.Tags); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if req.GeoRules != nil {
+		rules, err := normalizeGeoRules(*req.GeoRules)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		req.GeoRules = &rules
+	}
+	if req.DeviceRules != nil {
+		rules, err := normalizeDeviceRules(*req.DeviceRules)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		req.DeviceRules = &rules
+	}
+	if req.Variants != nil {
+		variants, err := normalizeVariants(*req.Variants)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		req.Variants = &variants
+	}
+	if req.QueryConflict != nil {
+		mode, err := normalizeQueryConflict(*req.QueryConflict)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		req.QueryConflict = &mode
+	}
+	var passwordHash string
+	if req.Password != nil {
+		if err := validateLinkPassword(*req.Password); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		var err error
+		if passwordHash, err = hashLinkPassword(*req.Password); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "server error"})
+			return
+		}
+	}
+	cleared := make(map[string]bool, len(req.Clear))
+	for _, f := range req.Clear {
+		if !clearableFields[f] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "cannot clear field " + f})
+			return
+		}
+		cleared[f] = true
+	}
+
+	userID, _ := authz.UserID(c)
+	db := storage.GetPostgres()
+
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server error"})
+		return
+	}
+	defer tx.Rollback()
+
+	// Verify ownership and lock the row so concurrent updates serialize.
+	owned, err := authz.LockOwnedLink(tx, req.ID, userID)
+	if err != nil {
+		authz.AbortWithLinkError(c, err)
+		return
+	}
+	oldSlug := owned.Slug
+
+	var (
+		link        = models.URLRequest{Slug: oldSlug}
+		expiresAt   sql.NullTime
+		activeFrom  sql.NullTime
+		maxClicks   sql.NullInt64
+		fallbackURL sql.NullString
+		folderID    sql.NullString
+		geoRules    sql.NullString
+		deviceRules sql.NullString
+		variants    sql.NullString
+		customSlug  bool
+	)
+	err = tx.QueryRow(`
+		SELECT original_url, expires_at, active_from, max_clicks, fallback_url, folder_id, geo_rules,
+		       device_rules, variants, sticky_variants, forward_query, query_conflict,
+		       COALESCE(custom_slug, FALSE)
+		FROM urls WHERE id = $1`, req.ID).
+		Scan(&link.OriginalURL, &expiresAt, &activeFrom, &maxClicks, &fallbackURL, &folderID, &geoRules,
+			&deviceRules, &variants, &link.StickyVariants, &link.ForwardQuery, &link.QueryConflict, &customSlug)
+	if err == nil {
+		err = scanJSONB(geoRules, "geo_rules", &link.GeoRules)
+	}
+	if err == nil {
+		err = scanJSONB(deviceRules, "device_rules", &link.DeviceRules)
+	}
+	if err == nil {
+		err = scanJSONB(variants, "variants", &link.Variants)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server error"})
+		return
+	}
+	link.FallbackURL = fallbackURL.String
+	link.FolderID = folderID.String
+	if expiresAt.Valid {
+		link.ExpiresAt = &expiresAt.Time
+	}
+	if activeFrom.Valid {
+		link.ActiveFrom = &activeFrom.Time
+	}
+	if maxClicks.Valid {
+		n := int(maxClicks.Int64)
+		link.MaxClicks = &n
+	}
+
+	// Validate only the settings being changed; an already expired link may
+	// still have its destination fixed.
+	changed := models.URLRequest{ExpiresAt: req.ExpiresAt, MaxClicks: req.MaxClicks, ActiveFrom: req.ActiveFrom}
+	if req.FallbackURL != nil {
+		changed.FallbackURL = *req.FallbackURL
+	}
+	if err := validateLinkSettings(&changed, time.Now()); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	limitsChanged := applyLinkUpdate(&link, req, cleared)
+	if link.OriginalURL == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "original_url cannot be empty"})
+		return
+	}
+	if link.ActiveFrom != nil && link.ExpiresAt != nil && !link.ActiveFrom.Before(*link.ExpiresAt) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "active_from must be before expires_at"})
+		return
+	}
+
+	if req.FolderID != nil && *req.FolderID != "" && !cleared["folder_id"] {
+		if _, err := collections.OwnedFolder(tx, userID, *req.FolderID); err != nil {
+			respondCollectionError(c, err, "folder not found")
+			return
+		}
+	}
+
+	renamed := link.Slug != oldSlug
+	if renamed {
+		if status, err := checkSlugRename(db, link.Slug); err != nil {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		if !customSlug {
+			limits, err := plans.ForUser(db, userID)
+			if err == nil {
+				err = plans.CheckCustomSlugs(db, userID, limits, 1)
+			}
+			if err != nil {
+				respondPlanError(c, err)
+				return
+			}
+		}
+	}
+
+	var protected bool
+	err = tx.QueryRow(`
+		UPDATE urls
+		SET original_url = $3, slug = $4, expires_at = $5, max_clicks = $6,
+		    active_from = $7, fallback_url = NULLIF($8, ''), custom_slug = custom_slug OR $9,
+		    folder_id = NULLIF($10, ''),
+		    password_hash = CASE WHEN $11 THEN NULL ELSE COALESCE(NULLIF($12, ''), password_hash) END,
+		    geo_rules = $13, device_rules = $14, variants = $15, sticky_variants = $16,
+		    forward_query = $17, query_conflict = $18
+		WHERE id = $1 AND user_id = $2
+		RETURNING password_hash IS NOT NULL`,
+		req.ID, userID, link.OriginalURL, link.Slug, link.ExpiresAt, link.MaxClicks,
+		link.ActiveFrom, link.FallbackURL, renamed, link.FolderID, cleared["password"], passwordHash,
+		jsonbValue(link.GeoRules, len(link.GeoRules)), jsonbValue(link.DeviceRules, len(link.DeviceRules)),
+		jsonbValue(link.Variants, len(link.Variants)), link.StickyVariants,
+		link.ForwardQuery, link.QueryConflict).
+		Scan(&protected)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			c.JSON(http.StatusConflict, gin.H{"error": "slug already in use"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update shortlink"})
+		return
+	}
+	if req.Tags != nil {
+		if err := collections.SetLinkTags(tx, userID, req.ID, tags); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update shortlink"})
+			return
+		}
+	}
+	linkTags, err := collections.LinkTags(tx, []string{req.ID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update shortlink"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update shortlink"})
+		return
+	}
+
+	invalidateSlugCache(req.ID, oldSlug, link.Slug, limitsChanged)
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":              req.ID,
+		"slug":            link.Slug,
+		"short_url":       getBaseURLFromRequest(c) + "/" + link.Slug,
+		"original_url":    link.OriginalURL,
+		"expires_at":      link.ExpiresAt,
+		"max_clicks":      link.MaxClicks,
+		"active_from":     link.ActiveFrom,
+		"fallback_url":    link.FallbackURL,
+		"folder_id":       nullIfEmpty(link.FolderID),
+		"tags":            orEmpty(linkTags[req.ID]),
+		"protected":       protected,
+		"geo_rules":       orEmpty(link.GeoRules),
+		"device_rules":    orEmpty(link.DeviceRules),
+		"variants":        orEmpty(link.Variants),
+		"sticky_variants": link.StickyVariants,
+		"forward_query":   link.ForwardQuery,
+		"query_conflict":  link.QueryConflict,
+	})
+}
+
+// applyLinkUpdate merges the requested changes into link. It reports whether
+// the expiry or click limit changed, which invalidates the click counter.
+func applyLinkUpdate(link *models.URLRequest, req models.URLUpdateRequest, cleared map[string]bool) bool {
+	limitsChanged := false
+	if req.OriginalURL != nil {
+		link.OriginalURL = *req.OriginalURL
+	}
+	if req.Slug != nil {
+		link.Slug = *req.Slug
+	}
+	if req.FallbackURL != nil {
+		link.FallbackURL = *req.FallbackURL
+	}
+	if req.ActiveFrom != nil {
+		link.ActiveFrom = utcTime(req.ActiveFrom)
+	}
+	if req.ExpiresAt != nil {
+		link.ExpiresAt = utcTime(req.ExpiresAt)
+		limitsChanged = true
+	}
+	if req.MaxClicks != nil {
+		link.MaxClicks = req.MaxClicks
+		limitsChanged = true
+	}
+
+	if cleared["expires_at"] {
+		link.ExpiresAt = nil
+		limitsChanged = true
+	}
+	if cleared["max_clicks"] {
+		link.MaxClicks = nil
+		limitsChanged = true
+	}
+	if cleared["active_from"] {
+		link.ActiveFrom = nil
+	}
+	if cleared["fallback_url"] {
+		link.FallbackURL = ""
+	}
+	if req.FolderID != nil {
+		link.FolderID = *req.FolderID
+	}
+	if cleared["folder_id"] {
+		link.FolderID = ""
+	}
+	if req.GeoRules != nil {
+		link.GeoRules = *req.GeoRules
+	}
+	if cleared["geo_rules"] {
+		link.GeoRules = nil
+	}
+	if req.DeviceRules != nil {
+		link.DeviceRules = *req.DeviceRules
+	}
+	if cleared["device_rules"] {
+		link.DeviceRules = nil
+	}
+	if req.Variants != nil {
+		link.Variants = *req.Variants
+	}
+	if req.StickyVariants != nil {
+		link.StickyVariants = *req.StickyVariants
+	}
+	if cleared["variants"] {
+		link.Variants = nil
+	}
+	if req.ForwardQuery != nil {
+		link.ForwardQuery = *req.ForwardQuery
+	}
+	if req.QueryConflict != nil {
+		link.QueryConflict = *req.QueryConflict
+	}
+	return limitsChanged
+}
+
+// checkSlugRename validates a new slug and checks that it is free both in
+// Postgres and in Redis, where public links live without a urls row.
+// It returns the HTTP status to use alongside any error.
+func checkSlugRename(db *sql.DB, slug string) (int, error) {
+	if err := utils.ValidateSlug(slug); err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	available, err := utils.IsSlugAvailable(db, slug)
+	if err != nil {
+		return http.StatusInternalServerError, errors.New("server error")
+	}
+	if available {
+		n, err := storage.RedisClient.Exists(storage.Ctx, "slug:"+slug).Result()
+		if err != nil {
+			return http.StatusInternalServerError, errors.New("server error")
+		}
+		available = n == 0
+	}
+	if !available {
+		return http.StatusConflict, errors.New("slug already in use")
+	}
+	return 0, nil
+}
+
+// invalidateSlugCache removes the cached entries for a link after an update.
+// Old and new slug keys are deleted in one transaction; the old key is
+// deleted again shortly after to drop entries re-cached by redirects that
+// raced with the update. A renamed link's QR code encodes the old short URL,
+// so its cached images are dropped and re-rendered on the next request.
+func invalidateSlugCache(id, oldSlug, newSlug string, limitsChanged bool) {
+	keys := []string{"slug:" + oldSlug}
+	if newSlug != oldSlug {
+		keys = append(keys, "slug:"+newSlug, qrKeyPrefix+id)
+		removeQRCodeFiles(id)
+	}
+	if limitsChanged {
+		keys = append(keys, clicksKeyPrefix+id)
+	}
+
+	pipe := storage.RedisClient.TxPipeline()
+	pipe.Del(storage.Ctx, keys...)
+	if _, err := pipe.Exec(storage.Ctx); err != nil {
+		log.Printf("failed to invalidate cache for shortlink %s: %v", id, err)
+	}
+
+	time.AfterFunc(staleCacheRecheck, func() {
+		if err := storage.RedisClient.Del(storage.Ctx, "slug:"+oldSlug).Err(); err != nil {
+			log.Printf("failed to re-invalidate cache for shortlink %s: %v", id, err)
+		}
+	})
+}
+
+// nullIfEmpty returns nil for "" so that unset IDs are reported as null.
+func nullIfEmpty(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// orEmpty returns s, or an empty slice instead of nil so that the field is
+// encoded as [] rather than null.
+func orEmpty[T any](s []T) []T {
+	if s == nil {
+		return []T{}
+	}
+	return s
+}
@@ -7,13 +7,18 @@ package urls
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"os"
 	"time"
 
+	"go_backend/internal/analytics"
+	"go_backend/internal/authz"
+	"go_backend/internal/collections"
 	"go_backend/internal/models"
-	"go_backend/internal/security"
+	"go_backend/internal/plans"
 	"go_backend/internal/storage"
 	"go_backend/internal/utils"
 
@@ -23,10 +28,23 @@ import (
 
 // SlugCache represents a cached URL entry.
 type SlugCache struct {
-	URL    string `json:"url"`
-	ID     string `json:"id"`
-	UserID string `json:"user_id"`
-	Plan   string `json:"plan"`
+	URL         string     `json:"url"`
+	ID          string     `json:"id"`
+	UserID      string     `json:"user_id"`
+	Plan        string     `json:"plan"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	ActiveFrom  *time.Time `json:"active_from,omitempty"`
+	MaxClicks   int        `json:"max_clicks,omitempty"`
+	FallbackURL string     `json:"fallback_url,omitempty"`
+	Protected   bool       `json:"protected,omitempty"` // password required, see UnlockURL
+	// GeoRules, DeviceRules and Variants are kept in the entry so that
+	// RedirectURL can pick a destination without a database round trip.
+	GeoRules       []models.GeoRule    `json:"geo_rules,omitempty"`
+	DeviceRules    []models.DeviceRule `json:"device_rules,omitempty"`
+	Variants       []models.Variant    `json:"variants,omitempty"`
+	StickyVariants bool                `json:"sticky_variants,omitempty"`
+	ForwardQuery   bool                `json:"forward_query,omitempty"`
+	QueryConflict  string              `json:"query_conflict,omitempty"`
 }
 
 // getBaseURLFromRequest returns the full base URL from the request or environment.
@@ -41,14 +59,47 @@ func getBaseURLFromRequest(c *gin.Context) string {
 	return fmt.Sprintf("%s://%s", scheme, c.Request.Host)
 }
 
+// respondPlanError writes 403 with the limit details for a *plans.LimitError
+// and 500 for any other error.
+func respondPlanError(c *gin.Context, err error) {
+	if le, ok := plans.AsLimitError(err); ok {
+		c.JSON(http.StatusForbidden, le)
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "server error"})
+}
+
+// respondCollectionError writes the response for an error from the
+// collections package: 404 with notFound for missing or foreign tags and
+// folders, 400 for invalid names and 500 otherwise.
+func respondCollectionError(c *gin.Context, err error, notFound string) {
+	switch {
+	case errors.Is(err, collections.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": notFound})
+	case errors.Is(err, collections.ErrInvalidName), errors.Is(err, collections.ErrTooManyTags):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server error"})
+	}
+}
+
 // ShortenPublicURL creates a public (unauthenticated) short URL stored in Reddis.
-// The generated URL automatically expires after 1 weeks.
+// The generated URL automatically expires after 1 weeks, or earlier when
+// expires_at is provided.
 func ShortenPublicURL(c *gin.Context) {
 	var input models.URLRequest
 	if err := c.ShouldBindJSON(&input); err != nil || input.OriginalURL == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
 		return
 	}
+	if input.ExpiresAt != nil && !input.ExpiresAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expires_at must be in the future"})
+		return
+	}
+	if input.Password != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "password-protected links require an account"})
+		return
+	}
 
 	// Generate a unique slug (you can still use DB if you want uniqueness checks)
 	slug, err := utils.GenerateRandomSlug(8) // or GenerateUniqueSlug if you check in Redis
@@ -59,9 +110,10 @@ func ShortenPublicURL(c *gin.Context) {
 
 	urlID := uuid.NewString()
 	cacheValue := SlugCache{
-		URL:  utils.EnsureProtocol(input.OriginalURL),
-		ID:   urlID,
-		Plan: "default",
+		URL:       utils.EnsureProtocol(input.OriginalURL),
+		ID:        urlID,
+		Plan:      "default",
+		ExpiresAt: input.ExpiresAt,
 	}
 
 	// Serialize to JSON
@@ -69,9 +121,9 @@ func ShortenPublicURL(c *gin.Context) {
 	cacheKey := "slug:" + slug
 
 	// Set in Redis with TTL
-	cacheTTL := 7 * 24 * time.Hour // 7 days = 1 week
-	// cacheTTL := 20 * time.Second // example 20s expiry
-	if err := storage.RedisClient.Set(storage.Ctx, cacheKey, jsonVal, cacheTTL).Err(); err != nil {
+	ttl := cacheTTL(cacheValue, 7*24*time.Hour) // 7 days = 1 week
+	// ttl := 20 * time.Second // example 20s expiry
+	if err := storage.RedisClient.Set(storage.Ctx, cacheKey, jsonVal, ttl).Err(); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Redis caching failed"})
 		return
 	}
@@ -143,22 +195,99 @@ func ShortenPublicURL(c *gin.Context) {
 // ShortenURL handles authenticated URL shortening requests.
 // It validates the user, generates a unique slug, stores the URL in Postgres,
 // caches the result in Redis, and returns the shortened URL.
+//
+// Optional expires_at, max_clicks, active_from and fallback_url fields limit
+// when and how often the link redirects. Plan quotas are enforced first and
+// reported with 403 and a body naming the exceeded limit.
+//
+// The link can be filed with folder_id, one of the user's folders, and
+// tagged with tags, a list of tag names; missing tags are created. With a
+// password, visitors must unlock the link before they are redirected.
+// device_rules and geo_rules send visitors on matching devices or from the
+// listed countries to other destinations; everyone else goes to
+// original_url, or is split between the weighted A/B variants. With
+// sticky_variants a cookie keeps each visitor on their first variant.
+//
+// utm fields are added to original_url as utm_* parameters. With
+// forward_query, the query string of the short URL is passed on to the
+// destination; query_conflict decides which value wins for parameters on
+// both.
 func ShortenURL(c *gin.Context) {
 	var input models.URLRequest
 	if err := c.ShouldBindJSON(&input); err != nil || input.OriginalURL == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
 		return
 	}
-
-	// Authenticate the user via JWT cookie
-	claims, err := security.ValidateJWTFromCookie(c)
+	if err := validateLinkSettings(&input, time.Now()); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.Slug != "" {
+		if err := utils.ValidateSlug(input.Slug); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	tags, err := collections.NormalizeTagNames(input.Tags)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.GeoRules, err = normalizeGeoRules(input.GeoRules); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.DeviceRules, err = normalizeDeviceRules(input.DeviceRules); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.Variants, err = normalizeVariants(input.Variants); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := normalizeQuerySettings(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	passwordHash, err := hashLinkPassword(input.Password)
 	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server error"})
+		return
+	}
+
+	// The user is authenticated by middleware.AuthMiddleware
+	userID, ok := authz.UserID(c)
+	if !ok {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
 
 	db := storage.GetPostgres()
 
+	if input.FolderID != "" {
+		if _, err := collections.OwnedFolder(db, userID, input.FolderID); err != nil {
+			respondCollectionError(c, err, "folder not found")
+			return
+		}
+	}
+
+	// Enforce plan quotas before reserving a slug
+	customSlugs := 0
+	if input.Slug != "" {
+		customSlugs = 1
+	}
+	limits, err := plans.ForUser(db, userID)
+	if err == nil {
+		err = plans.CheckLinkCreation(db, userID, limits, 1, customSlugs)
+	}
+	if err == nil && input.CreatedQRCode {
+		err = plans.CheckQRCode(db, userID, limits)
+	}
+	if err != nil {
+		respondPlanError(c, err)
+		return
+	}
+
 	// Generate a unique slug
 	slug, err := utils.GenerateUniqueSlug(db, input.Slug, 8)
 	if err != nil {
@@ -166,71 +295,201 @@ func ShortenURL(c *gin.Context) {
 		return
 	}
 
-	// Insert the new URL into the database
+	// Insert the new URL and its tags into the database
 	urlID := uuid.NewString()
-	_, err = db.Exec(`
-		INSERT INTO urls (id, user_id, original_url, slug, created_at)
-		VALUES ($1, $2, $3, $4, $5)`,
-		urlID, claims.UserID, input.OriginalURL, slug, time.Now())
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database insert failed"})
+		return
+	}
+	defer tx.Rollback()
+	_, err = tx.Exec(`
+		INSERT INTO urls (id, user_id, original_url, slug, custom_slug, created_at, expires_at, max_clicks, active_from, fallback_url, folder_id, password_hash,
+		                  geo_rules, device_rules, variants, sticky_variants, forward_query, query_conflict)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NULLIF($10, ''), NULLIF($11, ''), NULLIF($12, ''), $13, $14, $15, $16, $17, $18)`,
+		urlID, userID, input.OriginalURL, slug, customSlugs > 0, time.Now().UTC(),
+		input.ExpiresAt, input.MaxClicks, input.ActiveFrom, input.FallbackURL, input.FolderID, passwordHash,
+		jsonbValue(input.GeoRules, len(input.GeoRules)), jsonbValue(input.DeviceRules, len(input.DeviceRules)),
+		jsonbValue(input.Variants, len(input.Variants)), input.StickyVariants, input.ForwardQuery, input.QueryConflict)
+	if err == nil {
+		err = collections.SetLinkTags(tx, userID, urlID, tags)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database insert failed"})
 		return
 	}
 
 	// Cache the slug in Redis for fast retrieval
-	cacheValue := SlugCache{
-		URL:    utils.EnsureProtocol(input.OriginalURL),
-		ID:     urlID,
-		UserID: claims.UserID,
-	}
+	cacheValue := slugCacheFor(input, urlID, userID)
+	seedClickCounter(cacheValue, 0)
 	jsonVal, _ := json.Marshal(cacheValue)
 	cacheKey := "slug:" + slug
-	ttl := 24 * time.Hour // default TTL
+	ttl := cacheTTL(cacheValue, 24*time.Hour) // default TTL
 	if err := storage.RedisClient.Set(storage.Ctx, cacheKey, jsonVal, ttl).Err(); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Redis caching failed"})
 		return
 	}
 
-	// Return the shortened URL
+	// Generate a default QR code when requested; the link itself is
+	// already created, so failures are logged rather than returned.
 	baseURL := getBaseURLFromRequest(c)
+	if input.CreatedQRCode {
+		settings, _ := qrSettingsFromRequest(models.QRCodeRequest{})
+		if _, err := generateQRCode(db, urlID, baseURL+"/"+slug, settings); err != nil {
+			log.Printf("failed to generate QR code for %s: %v", urlID, err)
+		}
+	}
+
+	// Return the shortened URL
 	c.JSON(http.StatusOK, gin.H{
+		"id":        urlID,
 		"slug":      slug,
 		"short_url": baseURL + "/" + slug,
 	})
 }
 
 // RedirectURL redirects a short slug to its original URL.
-// Caching via Redis is used to reduce DB load.
+// Caching via Redis is used to reduce DB load, and every redirect of a
+// persisted link is queued for click analytics without touching Postgres.
+//
+// Links that are not yet active return 404; expired links and links past
+// their click limit redirect to their fallback URL or return 410 Gone.
+// Password-protected links that are available answer with a password
+// challenge until the visitor has unlocked them through UnlockURL.
+//
+// The destination is the URL of the first device rule matching the
+// visitor's User-Agent, else of the first geo rule matching the country of
+// their IP, else a weighted random A/B variant, else the original URL. The
+// chosen variant is recorded with the visit. Links with forward_query pass
+// the request's query string on.
 func RedirectURL(c *gin.Context) {
 	slug := c.Param("slug")
-	db := storage.GetPostgres()
 
 	cacheKey := "slug:" + slug
 	var cached SlugCache
 	val, err := storage.RedisClient.Get(storage.Ctx, cacheKey).Result()
-	if err == nil && json.Unmarshal([]byte(val), &cached) == nil {
-		c.Redirect(http.StatusFound, cached.URL)
-		return
+	if err != nil || json.Unmarshal([]byte(val), &cached) != nil {
+		var clicks int
+		cached, clicks, err = loadSlug(storage.GetPostgres(), slug)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				c.JSON(http.StatusNotFound, gin.H{"error": "URL not found"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+			}
+			return
+		}
+
+		if ttl := cacheTTL(cached, 6*time.Hour); ttl > 0 {
+			jsonVal, _ := json.Marshal(cached)
+			_ = storage.RedisClient.Set(storage.Ctx, cacheKey, jsonVal, ttl).Err()
+		}
+		seedClickCounter(cached, clicks)
 	}
 
-	var urlID, originalURL string
-	err = db.QueryRow(`SELECT id, original_url FROM urls WHERE slug = $1`, slug).Scan(&urlID, &originalURL)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{"error": "URL not found"})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Server error"})
+	// Unavailable links are reported before any password prompt; the click
+	// is only counted once the visitor is let through.
+	now := time.Now()
+	if cached.Protected {
+		if state := checkLinkState(cached, now, false); state != linkActive {
+			respondUnavailable(c, cached, state)
+			return
+		}
+		if !linkUnlocked(c, cached.ID) {
+			respondLocked(c, slug, http.StatusUnauthorized, "")
+			return
 		}
+	}
+
+	if state := checkLinkState(cached, now, true); state != linkActive {
+		respondUnavailable(c, cached, state)
 		return
 	}
 
-	originalURL = utils.EnsureProtocol(originalURL)
-	cacheValue := SlugCache{
-		URL: originalURL,
-		ID:  urlID,
+	dest, variant := linkDestination(c, cached)
+	if cached.ForwardQuery {
+		dest = forwardQuery(dest, c.Request.URL.RawQuery, cached.QueryConflict)
+	}
+	recordVisit(c, cached, variant)
+	c.Redirect(http.StatusFound, dest)
+}
+
+// loadSlug reads a slug's cache entry and current click count from Postgres.
+// It returns sql.ErrNoRows if the slug does not exist.
+func loadSlug(db *sql.DB, slug string) (SlugCache, int, error) {
+	var (
+		entry       SlugCache
+		userID      sql.NullString
+		fallbackURL sql.NullString
+		maxClicks   sql.NullInt64
+		clicks      sql.NullInt64
+		expiresAt   sql.NullTime
+		activeFrom  sql.NullTime
+		geoRules    sql.NullString
+		deviceRules sql.NullString
+		variants    sql.NullString
+	)
+	err := db.QueryRow(`
+		SELECT id, user_id, original_url, expires_at, active_from, max_clicks, fallback_url, click_count,
+		       password_hash IS NOT NULL, geo_rules, device_rules, variants, sticky_variants,
+		       forward_query, query_conflict
+		FROM urls WHERE slug = $1`, slug).
+		Scan(&entry.ID, &userID, &entry.URL, &expiresAt, &activeFrom, &maxClicks, &fallbackURL, &clicks,
+			&entry.Protected, &geoRules, &deviceRules, &variants, &entry.StickyVariants,
+			&entry.ForwardQuery, &entry.QueryConflict)
+	if err == nil {
+		err = scanJSONB(geoRules, "geo_rules", &entry.GeoRules)
+	}
+	if err == nil {
+		err = scanJSONB(deviceRules, "device_rules", &entry.DeviceRules)
+	}
+	if err == nil {
+		err = scanJSONB(variants, "variants", &entry.Variants)
+	}
+	if err != nil {
+		return entry, 0, err
 	}
-	jsonVal, _ := json.Marshal(cacheValue)
-	_ = storage.RedisClient.Set(storage.Ctx, cacheKey, jsonVal, 6*time.Hour).Err()
 
-	c.Redirect(http.StatusFound, originalURL)
+	entry.URL = utils.EnsureProtocol(entry.URL)
+	entry.UserID = userID.String
+	entry.FallbackURL = fallbackURL.String
+	entry.MaxClicks = int(maxClicks.Int64)
+	if expiresAt.Valid {
+		entry.ExpiresAt = &expiresAt.Time
+	}
+	if activeFrom.Valid {
+		entry.ActiveFrom = &activeFrom.Time
+	}
+	return entry, int(clicks.Int64), nil
+}
+
+// linkDestination picks the redirect target for a visitor: device rules
+// first, then geo rules, then the A/B variants, then the link's URL. It also
+// returns the name of the chosen variant, if any.
+func linkDestination(c *gin.Context, link SlugCache) (string, string) {
+	if dest, ok := deviceDestination(link, c.Request.UserAgent()); ok {
+		return dest, ""
+	}
+	if dest, ok := geoDestination(link, c.ClientIP()); ok {
+		return dest, ""
+	}
+	if len(link.Variants) > 0 {
+		v := pickVariant(c, link)
+		return utils.EnsureProtocol(v.URL), v.Name
+	}
+	return link.URL, ""
+}
+
+// recordVisit queues a click event for links persisted in Postgres.
+// Public links live only in Redis and have no urls row to attach visits to.
+func recordVisit(c *gin.Context, link SlugCache, variant string) {
+	if link.UserID == "" {
+		return
+	}
+	visit := analytics.NewVisit(c.Request, link.ID, c.ClientIP())
+	visit.Variant = variant
+	analytics.Record(visit)
 }
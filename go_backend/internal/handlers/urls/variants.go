@@ -0,0 +1,101 @@
+package urls
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/url"
+	"strings"
+
+	"go_backend/internal/models"
+	"go_backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Limits on the A/B variants of one link.
+const (
+	maxVariants          = 10
+	maxVariantNameLength = 32
+	maxVariantWeight     = 1000
+)
+
+// normalizeVariants validates the A/B variants of a link. A link has either
+// no variants or at least two, with unique names made of letters, digits,
+// '-' and '_' so that they can be stored in the sticky cookie.
+func normalizeVariants(variants []models.Variant) ([]models.Variant, error) {
+	if len(variants) == 0 {
+		return nil, nil
+	}
+	if len(variants) < 2 || len(variants) > maxVariants {
+		return nil, fmt.Errorf("a link needs 2-%d variants", maxVariants)
+	}
+	out := make([]models.Variant, len(variants))
+	seen := make(map[string]bool, len(variants))
+	for i, v := range variants {
+		v.Name = strings.TrimSpace(v.Name)
+		if err := validateVariantName(v.Name); err != nil {
+			return nil, fmt.Errorf("variants[%d]: %w", i, err)
+		}
+		if seen[v.Name] {
+			return nil, fmt.Errorf("variants[%d]: duplicate name %q", i, v.Name)
+		}
+		seen[v.Name] = true
+		if v.Weight < 1 || v.Weight > maxVariantWeight {
+			return nil, fmt.Errorf("variants[%d]: weight must be 1-%d", i, maxVariantWeight)
+		}
+		if u, err := url.Parse(utils.EnsureProtocol(v.URL)); err != nil || u.Host == "" {
+			return nil, fmt.Errorf("variants[%d]: url must be an absolute URL", i)
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+// validateVariantName checks the length and characters of a variant name.
+func validateVariantName(name string) error {
+	if name == "" || len(name) > maxVariantNameLength {
+		return fmt.Errorf("name must be 1-%d characters", maxVariantNameLength)
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return errors.New("name may only contain letters, digits, '-' and '_'")
+		}
+	}
+	return nil
+}
+
+// pickVariant chooses the variant a visitor is sent to. With sticky variants
+// a visitor keeps the variant named in their cookie for as long as it
+// exists; otherwise, and for new visitors, the choice is random in
+// proportion to the weights.
+func pickVariant(c *gin.Context, link SlugCache) models.Variant {
+	if link.StickyVariants {
+		if cookie, err := c.Cookie(utils.LinkVariantCookieName); err == nil {
+			for _, v := range link.Variants {
+				if v.Name == cookie {
+					return v
+				}
+			}
+		}
+	}
+
+	total := 0
+	for _, v := range link.Variants {
+		total += v.Weight
+	}
+	chosen := link.Variants[len(link.Variants)-1]
+	n := rand.IntN(total)
+	for _, v := range link.Variants {
+		if n < v.Weight {
+			chosen = v
+			break
+		}
+		n -= v.Weight
+	}
+
+	if link.StickyVariants {
+		utils.SetLinkVariantCookie(c, c.Param("slug"), chosen.Name)
+	}
+	return chosen
+}
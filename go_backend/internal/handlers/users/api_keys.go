@@ -0,0 +1,96 @@
+package users
+
+import (
+	"errors"
+	"net/http"
+
+	"go_backend/internal/apikeys"
+	"go_backend/internal/models"
+	"go_backend/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateAPIKey issues a personal API key. The key is only returned in this
+// response; afterwards only its prefix is shown.
+//
+// Example request:
+//
+//	POST /api/user/api-keys
+//	{
+//	  "name": "deploy script",
+//	  "scopes": ["read", "write"]
+//	}
+//
+// Responses:
+//
+//	201 Created - key created, secret in "key"
+//	400 Bad Request - invalid input or scopes
+//	403 Forbidden - apikeys.MaxKeysPerUser active keys already exist
+//	500 Internal Server Error - DB failure
+func CreateAPIKey(c *gin.Context) {
+	userID := c.MustGet("userID").(string)
+
+	var input models.CreateAPIKeyInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid input"})
+		return
+	}
+	scopes, err := apikeys.NormalizeScopes(input.Scopes)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	key, secret, err := apikeys.Create(storage.GetPostgres(), userID, input.Name, scopes)
+	if err != nil {
+		if errors.Is(err, apikeys.ErrTooManyKeys) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "max": apikeys.MaxKeysPerUser})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create api key"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"api_key": key,
+		"key":     secret,
+	})
+}
+
+// GetAPIKeys lists the user's active API keys with their last-used time.
+//
+// Example request:
+//
+//	GET /api/user/api-keys
+func GetAPIKeys(c *gin.Context) {
+	userID := c.MustGet("userID").(string)
+
+	keys, err := apikeys.List(storage.GetPostgres(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load api keys"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"api_keys": keys})
+}
+
+// RevokeAPIKey disables one of the user's API keys immediately.
+//
+// Example request:
+//
+//	DELETE /api/user/api-keys/:id
+func RevokeAPIKey(c *gin.Context) {
+	userID := c.MustGet("userID").(string)
+
+	if err := apikeys.Revoke(storage.GetPostgres(), userID, c.Param("id")); err != nil {
+		if errors.Is(err, apikeys.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "api key not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke api key"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "api key revoked"})
+}
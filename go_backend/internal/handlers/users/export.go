@@ -0,0 +1,361 @@
+package users
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go_backend/internal/plans"
+	"go_backend/internal/storage"
+	"go_backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// exportFetchSize is the number of rows fetched from the cursor at a time,
+// which bounds the memory an export uses regardless of account size.
+const exportFetchSize = 500
+
+// Export formats selected with ?format=.
+const (
+	formatCSV    = "csv"
+	formatNDJSON = "ndjson"
+)
+
+// exportFilter holds the query parameters shared by both exports.
+type exportFilter struct {
+	format string
+	from   *time.Time
+	to     *time.Time
+	search string
+	linkID string
+}
+
+// linkExportColumns are the CSV columns, and JSON keys, of a link export.
+var linkExportColumns = []string{
+	"id", "slug", "original_url", "created_at", "click_count", "last_clicked_at",
+	"expires_at", "max_clicks", "active_from", "fallback_url", "created_qrcode", "custom_slug",
+}
+
+// visitExportColumns are the CSV columns, and JSON keys, of a visit export.
+var visitExportColumns = []string{
+	"link_id", "slug", "visited_at", "referer", "country", "region", "city",
+	"browser", "os", "device", "variant", "is_unique",
+}
+
+// ExportUserLinks streams all of the user's links as CSV or newline-delimited
+// JSON. Rows are read through a server-side cursor, so large accounts are
+// never loaded into memory at once.
+//
+// Query parameters:
+//
+//	format   csv (default) or ndjson
+//	from, to created_at range, RFC 3339 or YYYY-MM-DD
+//	q        case-insensitive match on slug or destination
+//
+// Example request:
+//
+//	GET /api/user/export/links?format=ndjson&from=2025-01-01
+func ExportUserLinks(c *gin.Context) {
+	userID := c.MustGet("userID").(string)
+	f, err := parseExportFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	where, args := []string{"user_id = $1"}, []interface{}{userID}
+	if f.from != nil {
+		args = append(args, *f.from)
+		where = append(where, fmt.Sprintf("created_at >= $%d", len(args)))
+	}
+	if f.to != nil {
+		args = append(args, *f.to)
+		where = append(where, fmt.Sprintf("created_at < $%d", len(args)))
+	}
+	if f.search != "" {
+		args = append(args, "%"+escapeLike(f.search)+"%")
+		where = append(where, fmt.Sprintf("(slug ILIKE $%d OR original_url ILIKE $%d)", len(args), len(args)))
+	}
+
+	query := `
+		SELECT id, slug, original_url, created_at, COALESCE(click_count, 0), last_clicked_at,
+		       expires_at, max_clicks, active_from, COALESCE(fallback_url, ''), created_qrcode, custom_slug
+		FROM urls
+		WHERE ` + strings.Join(where, " AND ") + `
+		ORDER BY created_at DESC`
+
+	streamExport(c, "links", f.format, linkExportColumns, query, args, func(rows *sql.Rows) ([]interface{}, error) {
+		var (
+			id, slug, originalURL, fallbackURL string
+			createdAt                          time.Time
+			clicks                             int64
+			lastClicked, expiresAt, activeFrom sql.NullTime
+			maxClicks                          sql.NullInt64
+			createdQRCode, customSlug          bool
+		)
+		err := rows.Scan(&id, &slug, &originalURL, &createdAt, &clicks, &lastClicked,
+			&expiresAt, &maxClicks, &activeFrom, &fallbackURL, &createdQRCode, &customSlug)
+		return []interface{}{
+			id, slug, originalURL, createdAt, clicks, lastClicked,
+			expiresAt, maxClicks, activeFrom, fallbackURL, createdQRCode, customSlug,
+		}, err
+	})
+}
+
+// ExportUserVisits streams the click history of the user's links as CSV or
+// newline-delimited JSON, oldest first. Visits older than the plan's
+// analytics retention are not exported. IP addresses are never included.
+//
+// Query parameters:
+//
+//	format   csv (default) or ndjson
+//	from, to visited_at range, RFC 3339 or YYYY-MM-DD
+//	q        case-insensitive match on the link's slug or destination
+//	link_id  only visits of this link
+//
+// Example request:
+//
+//	GET /api/user/export/visits?link_id=<id>&from=2025-06-01
+func ExportUserVisits(c *gin.Context) {
+	userID := c.MustGet("userID").(string)
+	f, err := parseExportFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	limits, err := plans.ForUser(storage.GetPostgres(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch plan"})
+		return
+	}
+	from := limits.RetentionStart(time.Now())
+	if f.from != nil && f.from.After(from) {
+		from = *f.from
+	}
+
+	where, args := []string{"u.user_id = $1", "v.visited_at >= $2"}, []interface{}{userID, from}
+	if f.to != nil {
+		args = append(args, *f.to)
+		where = append(where, fmt.Sprintf("v.visited_at < $%d", len(args)))
+	}
+	if f.linkID != "" {
+		args = append(args, f.linkID)
+		where = append(where, fmt.Sprintf("u.id = $%d", len(args)))
+	}
+	if f.search != "" {
+		args = append(args, "%"+escapeLike(f.search)+"%")
+		where = append(where, fmt.Sprintf("(u.slug ILIKE $%d OR u.original_url ILIKE $%d)", len(args), len(args)))
+	}
+
+	query := `
+		SELECT u.id, u.slug, v.visited_at, COALESCE(v.referer, ''), COALESCE(v.country, ''),
+		       COALESCE(v.region, ''), COALESCE(v.city, ''), COALESCE(v.browser, ''),
+		       COALESCE(v.os, ''), COALESCE(v.device, ''), COALESCE(v.variant, ''), COALESCE(v.is_unique, FALSE)
+		FROM url_visits v
+		JOIN urls u ON u.id = v.url_id
+		WHERE ` + strings.Join(where, " AND ") + `
+		ORDER BY v.visited_at`
+
+	streamExport(c, "visits", f.format, visitExportColumns, query, args, func(rows *sql.Rows) ([]interface{}, error) {
+		var (
+			linkID, slug, referer, country, region, city, browser, os, device, variant string
+			visitedAt                                                                  time.Time
+			unique                                                                     bool
+		)
+		err := rows.Scan(&linkID, &slug, &visitedAt, &referer, &country, &region, &city, &browser, &os, &device,
+			&variant, &unique)
+		return []interface{}{
+			linkID, slug, visitedAt, referer, country, region, city, browser, os, device, variant, unique,
+		}, err
+	})
+}
+
+// parseExportFilter reads and validates the export query parameters.
+func parseExportFilter(c *gin.Context) (exportFilter, error) {
+	f := exportFilter{
+		format: strings.ToLower(c.DefaultQuery("format", formatCSV)),
+		search: strings.TrimSpace(c.Query("q")),
+		linkID: c.Query("link_id"),
+	}
+	if f.format != formatCSV && f.format != formatNDJSON {
+		return f, errors.New("format must be csv or ndjson")
+	}
+	for name, dst := range map[string]**time.Time{"from": &f.from, "to": &f.to} {
+		v := c.Query(name)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			if t, err = time.Parse("2006-01-02", v); err != nil {
+				return f, fmt.Errorf("%s must be an RFC 3339 timestamp or YYYY-MM-DD date", name)
+			}
+		}
+		t = t.UTC()
+		*dst = &t
+	}
+	if f.from != nil && f.to != nil && !f.from.Before(*f.to) {
+		return f, errors.New("from must precede to")
+	}
+	return f, nil
+}
+
+// escapeLike escapes the LIKE wildcards in a user-supplied search term.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// streamExport runs query through a server-side cursor in a read-only
+// transaction and writes each row, as produced by scan, in the requested
+// format. Once streaming has started the status can no longer change, so
+// later errors are logged and the response is cut short.
+func streamExport(c *gin.Context, name, format string, columns []string, query string, args []interface{}, scan func(*sql.Rows) ([]interface{}, error)) {
+	ctx := c.Request.Context()
+	tx, err := storage.GetPostgres().BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not start export"})
+		return
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DECLARE export_cursor NO SCROLL CURSOR FOR "+query, args...); err != nil {
+		log.Printf("export %s: declare cursor: %v", name, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not start export"})
+		return
+	}
+
+	ext, contentType := "csv", "text/csv; charset=utf-8"
+	if format == formatNDJSON {
+		ext, contentType = "ndjson", "application/x-ndjson"
+	}
+	filename := fmt.Sprintf("shortly-%s-%s.%s", name, time.Now().UTC().Format("2006-01-02"), ext)
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK)
+
+	w := newExportWriter(c.Writer, format, columns)
+	fetch := fmt.Sprintf("FETCH %d FROM export_cursor", exportFetchSize)
+	for {
+		rows, err := tx.QueryContext(ctx, fetch)
+		if err != nil {
+			log.Printf("export %s: fetch: %v", name, err)
+			return
+		}
+		n := 0
+		for rows.Next() {
+			values, err := scan(rows)
+			if err == nil {
+				err = w.write(values)
+			}
+			if err != nil {
+				rows.Close()
+				log.Printf("export %s: %v", name, err)
+				return
+			}
+			n++
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			log.Printf("export %s: fetch: %v", name, err)
+			return
+		}
+		if err := w.flush(); err != nil {
+			// Client went away.
+			return
+		}
+		c.Writer.Flush()
+		if n < exportFetchSize {
+			return
+		}
+	}
+}
+
+// exportWriter encodes rows as CSV (with a header row) or as one JSON
+// object per line keyed by column name.
+type exportWriter struct {
+	columns []string
+	csv     *csv.Writer
+	json    *json.Encoder
+}
+
+func newExportWriter(w io.Writer, format string, columns []string) *exportWriter {
+	ew := &exportWriter{columns: columns}
+	if format == formatNDJSON {
+		ew.json = json.NewEncoder(w)
+		return ew
+	}
+	ew.csv = csv.NewWriter(w)
+	_ = ew.csv.Write(columns)
+	return ew
+}
+
+func (w *exportWriter) write(values []interface{}) error {
+	if w.json != nil {
+		obj := make(map[string]interface{}, len(values))
+		for i, v := range values {
+			obj[w.columns[i]] = jsonValue(v)
+		}
+		return w.json.Encode(obj)
+	}
+	record := make([]string, len(values))
+	for i, v := range values {
+		record[i] = csvValue(v)
+	}
+	return w.csv.Write(record)
+}
+
+func (w *exportWriter) flush() error {
+	if w.csv != nil {
+		w.csv.Flush()
+		return w.csv.Error()
+	}
+	return nil
+}
+
+// jsonValue converts nullable scan types to their JSON form.
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case sql.NullTime:
+		if !v.Valid {
+			return nil
+		}
+		return v.Time.UTC().Format(time.RFC3339)
+	case sql.NullInt64:
+		if !v.Valid {
+			return nil
+		}
+		return v.Int64
+	case time.Time:
+		return v.UTC().Format(time.RFC3339)
+	}
+	return v
+}
+
+// csvValue formats a scanned value as a CSV field; NULLs become empty.
+// Strings that spreadsheets would evaluate as formulas are escaped with
+// utils.EscapeCSVFormula.
+func csvValue(v interface{}) string {
+	switch v := jsonValue(v).(type) {
+	case nil:
+		return ""
+	case string:
+		return utils.EscapeCSVFormula(v)
+	case bool:
+		return strconv.FormatBool(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	default:
+		return fmt.Sprint(v)
+	}
+}
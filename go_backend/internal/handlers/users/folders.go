@@ -0,0 +1,96 @@
+package users
+
+import (
+	"net/http"
+
+	"go_backend/internal/collections"
+	"go_backend/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetFolders lists the user's folders with the number of links in each.
+//
+// Example request:
+//
+//	GET /api/user/folders
+func GetFolders(c *gin.Context) {
+	userID := c.MustGet("userID").(string)
+
+	folders, err := collections.ListFolders(storage.GetPostgres(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load folders"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"folders": folders})
+}
+
+// CreateFolder adds a folder. Links are filed with folder_id when they are
+// created or updated.
+//
+// Example request:
+//
+//	POST /api/user/folders
+//	{"name": "Newsletter"}
+//
+// Responses:
+//
+//	201 Created - folder created
+//	400 Bad Request - invalid name
+//	409 Conflict - a folder with this name exists
+//	500 Internal Server Error - DB failure
+func CreateFolder(c *gin.Context) {
+	userID := c.MustGet("userID").(string)
+
+	name, ok := bindName(c)
+	if !ok {
+		return
+	}
+	folder, err := collections.CreateFolder(storage.GetPostgres(), userID, name)
+	if err != nil {
+		respondCollectionError(c, err, "folder not found")
+		return
+	}
+
+	c.JSON(http.StatusCreated, folder)
+}
+
+// RenameFolder changes the name of one of the user's folders.
+//
+// Example request:
+//
+//	PATCH /api/user/folders/:id
+//	{"name": "Newsletter 2026"}
+func RenameFolder(c *gin.Context) {
+	userID := c.MustGet("userID").(string)
+
+	name, ok := bindName(c)
+	if !ok {
+		return
+	}
+	folder, err := collections.RenameFolder(storage.GetPostgres(), userID, c.Param("id"), name)
+	if err != nil {
+		respondCollectionError(c, err, "folder not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, folder)
+}
+
+// DeleteFolder deletes one of the user's folders. Its links are kept and
+// move out of the folder.
+//
+// Example request:
+//
+//	DELETE /api/user/folders/:id
+func DeleteFolder(c *gin.Context) {
+	userID := c.MustGet("userID").(string)
+
+	if err := collections.DeleteFolder(storage.GetPostgres(), userID, c.Param("id")); err != nil {
+		respondCollectionError(c, err, "folder not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "folder deleted"})
+}
@@ -8,7 +8,10 @@ package users
 
 import (
 	"database/sql"
+	"go_backend/internal/collections"
+	"go_backend/internal/plans"
 	"go_backend/internal/storage"
+	"log"
 	"net/http"
 	"time"
 
@@ -44,47 +47,166 @@ func GetUserDetails(c *gin.Context) {
 	c.JSON(http.StatusOK, user)
 }
 
+// GetUserShortLinks returns one page of the user's links.
+//
+// Query parameters:
+//
+//	limit                    page size, 1-200 (default 50)
+//	cursor                   next_cursor from the previous page
+//	sort                     created_at (default), clicks or last_clicked_at
+//	order                    desc (default) or asc
+//	q                        case-insensitive match on slug or destination
+//	has_qr                   true/false
+//	expired                  true/false (past expires_at or out of clicks)
+//	tag                      tag ID
+//	folder                   folder ID, or "none" for links outside folders
+//	created_from, created_to RFC 3339 timestamp or YYYY-MM-DD date
+//
+// The response holds the page in "links", the number of links matching the
+// filters in "total" and, when more links follow, "next_cursor".
 func GetUserShortLinks(c *gin.Context) {
 	userID := c.MustGet("userID").(string)
 	db := storage.GetPostgres()
 
-	rows, err := db.Query(`
-		SELECT id, original_url, slug, created_at, created_qrcode, click_count, last_clicked_at 
-		FROM urls 
-		WHERE user_id=$1 
-		ORDER BY created_at DESC
-	`, userID)
+	q, err := parseLinkListQuery(c, userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var total int
+	if err := db.QueryRow(q.countSQL(), q.args...).Scan(&total); err != nil {
+		log.Printf("users: count links for %s: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch URLs"})
+		return
+	}
 
+	// pageSQL adds the cursor arguments, so build it before reading q.args.
+	page := q.pageSQL(`
+		id, original_url, slug, created_at, created_qrcode, COALESCE(click_count, 0), last_clicked_at,
+		expires_at, max_clicks, folder_id, password_hash IS NOT NULL`)
+	rows, err := db.Query(page, q.args...)
 	if err != nil {
+		log.Printf("users: list links for %s: %v", userID, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch URLs"})
 		return
 	}
 	defer rows.Close()
 
 	type ShortLink struct {
-		ID            string  `json:"id"`
-		OriginalURL   string  `json:"original_url"`
-		Slug          string  `json:"slug"`
-		CreatedAt     string  `json:"created_at"`
-		CreatedQRCode bool    `json:"created_qrcode"` // make sure this is in the
-		ClickCount    int     `json:"click_count"`
-		LastClickedAt *string `json:"last_clicked_at"` // nullable field
-
+		ID            string               `json:"id"`
+		OriginalURL   string               `json:"original_url"`
+		Slug          string               `json:"slug"`
+		CreatedAt     time.Time            `json:"created_at"`
+		CreatedQRCode bool                 `json:"created_qrcode"`
+		ClickCount    int                  `json:"click_count"`
+		LastClickedAt *time.Time           `json:"last_clicked_at"` // nullable field
+		ExpiresAt     *time.Time           `json:"expires_at"`
+		MaxClicks     *int                 `json:"max_clicks"`
+		FolderID      *string              `json:"folder_id"`
+		Tags          []collections.TagRef `json:"tags"`
+		Protected     bool                 `json:"protected"`
 	}
 
-	var links []ShortLink
+	links := make([]ShortLink, 0, q.limit)
+	var next *string
+	var prevSortKey string
 	for rows.Next() {
-		var l ShortLink
-		var lastClicked sql.NullTime
-
-		if err := rows.Scan(&l.ID, &l.OriginalURL, &l.Slug, &l.CreatedAt, &l.CreatedQRCode, &l.ClickCount, &lastClicked); err == nil {
-			if lastClicked.Valid {
-				t := lastClicked.Time.Format(time.RFC3339)
-				l.LastClickedAt = &t
-			}
-			links = append(links, l)
+		var (
+			l                      ShortLink
+			lastClicked, expiresAt sql.NullTime
+			maxClicks              sql.NullInt32
+			folderID               sql.NullString
+			sortKey                string
+		)
+		if err := rows.Scan(&l.ID, &l.OriginalURL, &l.Slug, &l.CreatedAt, &l.CreatedQRCode, &l.ClickCount,
+			&lastClicked, &expiresAt, &maxClicks, &folderID, &l.Protected, &sortKey); err != nil {
+			log.Printf("users: scan link for %s: %v", userID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not read URLs"})
+			return
+		}
+		if len(links) == q.limit {
+			// The extra row only tells us that another page exists.
+			prev := links[len(links)-1]
+			cur := linkCursor{Value: prevSortKey, ID: prev.ID}.encode()
+			next = &cur
+			break
+		}
+		if lastClicked.Valid {
+			l.LastClickedAt = &lastClicked.Time
+		}
+		if expiresAt.Valid {
+			l.ExpiresAt = &expiresAt.Time
+		}
+		if maxClicks.Valid {
+			n := int(maxClicks.Int32)
+			l.MaxClicks = &n
+		}
+		if folderID.Valid {
+			l.FolderID = &folderID.String
+		}
+		links = append(links, l)
+		prevSortKey = sortKey
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("users: list links for %s: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not read URLs"})
+		return
+	}
+
+	ids := make([]string, len(links))
+	for i, l := range links {
+		ids[i] = l.ID
+	}
+	tags, err := collections.LinkTags(db, ids)
+	if err != nil {
+		log.Printf("users: list link tags for %s: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch URLs"})
+		return
+	}
+	for i := range links {
+		links[i].Tags = tags[links[i].ID]
+		if links[i].Tags == nil {
+			links[i].Tags = []collections.TagRef{}
 		}
 	}
 
-	c.JSON(http.StatusOK, links)
+	c.JSON(http.StatusOK, gin.H{
+		"links":       links,
+		"total":       total,
+		"next_cursor": next,
+	})
+}
+
+// GetUserPlanLimits returns the caller's plan limits together with their
+// current usage, so clients can show remaining quota before hitting a 403.
+func GetUserPlanLimits(c *gin.Context) {
+	userID := c.MustGet("userID").(string)
+	db := storage.GetPostgres()
+
+	limits, err := plans.ForUser(db, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch plan"})
+		return
+	}
+
+	var usage struct {
+		TotalLinks    int `json:"total_links"`
+		LinksPerMonth int `json:"links_per_month"`
+		CustomSlugs   int `json:"custom_slugs"`
+		QRCodes       int `json:"qr_codes"`
+	}
+	err = db.QueryRow(`
+		SELECT COUNT(*),
+		       COUNT(*) FILTER (WHERE created_at >= date_trunc('month', NOW())),
+		       COUNT(*) FILTER (WHERE custom_slug),
+		       COUNT(*) FILTER (WHERE created_qrcode)
+		FROM urls WHERE user_id = $1
+	`, userID).Scan(&usage.TotalLinks, &usage.LinksPerMonth, &usage.CustomSlugs, &usage.QRCodes)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not fetch usage"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"limits": limits, "usage": usage})
 }
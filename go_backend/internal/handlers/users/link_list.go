@@ -0,0 +1,189 @@
+package users
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Page sizes for GetUserShortLinks.
+const (
+	defaultLinkPageSize = 50
+	maxLinkPageSize     = 200
+)
+
+// linkSorts maps the ?sort= values to their SQL sort key and the cast that
+// turns a cursor value back into that type. Nullable columns are coalesced
+// so that every row has a comparable key.
+var linkSorts = map[string]struct{ expr, cast string }{
+	"created_at":      {"created_at", "timestamp"},
+	"clicks":          {"COALESCE(click_count, 0)", "bigint"},
+	"last_clicked_at": {"COALESCE(last_clicked_at, 'epoch'::timestamp)", "timestamp"},
+}
+
+// pgTimestampLayout matches the text form of a Postgres timestamp, which is
+// how cursors carry timestamp sort keys.
+const pgTimestampLayout = "2006-01-02 15:04:05.999999999"
+
+// linkCursor marks the last row of a page: its sort key, as text, and its ID
+// as tie-breaker.
+type linkCursor struct {
+	Value string `json:"v"`
+	ID    string `json:"id"`
+}
+
+func (c linkCursor) encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeLinkCursor decodes a cursor for the given sort. Its value must parse
+// as that sort's key type, so tampered cursors are rejected here rather than
+// by the database cast.
+func decodeLinkCursor(s, sort string) (linkCursor, error) {
+	var c linkCursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err == nil {
+		err = json.Unmarshal(b, &c)
+	}
+	if err == nil {
+		switch linkSorts[sort].cast {
+		case "timestamp":
+			_, err = time.Parse(pgTimestampLayout, c.Value)
+		case "bigint":
+			_, err = strconv.ParseInt(c.Value, 10, 64)
+		}
+	}
+	if err != nil || c.ID == "" {
+		return c, errors.New("invalid cursor")
+	}
+	return c, nil
+}
+
+// linkListQuery is a parsed GetUserShortLinks request.
+type linkListQuery struct {
+	limit  int
+	sort   string
+	desc   bool
+	cursor *linkCursor
+	where  []string
+	args   []interface{}
+}
+
+// arg adds a query argument and returns its placeholder.
+func (q *linkListQuery) arg(v interface{}) string {
+	q.args = append(q.args, v)
+	return "$" + strconv.Itoa(len(q.args))
+}
+
+// parseLinkListQuery reads pagination, sorting and filter parameters. The
+// filters are collected in where/args, scoped to userID.
+func parseLinkListQuery(c *gin.Context, userID string) (*linkListQuery, error) {
+	q := &linkListQuery{
+		limit: defaultLinkPageSize,
+		sort:  c.DefaultQuery("sort", "created_at"),
+		desc:  true,
+	}
+	q.where = []string{"user_id = " + q.arg(userID)}
+
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxLinkPageSize {
+			return nil, fmt.Errorf("limit must be between 1 and %d", maxLinkPageSize)
+		}
+		q.limit = n
+	}
+	if _, ok := linkSorts[q.sort]; !ok {
+		return nil, errors.New("sort must be created_at, clicks or last_clicked_at")
+	}
+	switch strings.ToLower(c.DefaultQuery("order", "desc")) {
+	case "desc":
+	case "asc":
+		q.desc = false
+	default:
+		return nil, errors.New("order must be asc or desc")
+	}
+	if v := c.Query("cursor"); v != "" {
+		cur, err := decodeLinkCursor(v, q.sort)
+		if err != nil {
+			return nil, err
+		}
+		q.cursor = &cur
+	}
+
+	if v := strings.TrimSpace(c.Query("q")); v != "" {
+		p := q.arg("%" + escapeLike(v) + "%")
+		q.where = append(q.where, fmt.Sprintf("(slug ILIKE %s OR original_url ILIKE %s)", p, p))
+	}
+	if v := c.Query("has_qr"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, errors.New("has_qr must be true or false")
+		}
+		q.where = append(q.where, "created_qrcode = "+q.arg(b))
+	}
+	if v := c.Query("expired"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, errors.New("expired must be true or false")
+		}
+		// A link is expired once past expires_at or out of clicks.
+		expired := "((expires_at IS NOT NULL AND expires_at <= NOW()) OR (max_clicks IS NOT NULL AND COALESCE(click_count, 0) >= max_clicks))"
+		if !b {
+			expired = "NOT " + expired
+		}
+		q.where = append(q.where, expired)
+	}
+	if v := c.Query("tag"); v != "" {
+		q.where = append(q.where, "id IN (SELECT url_id FROM url_tags WHERE tag_id = "+q.arg(v)+")")
+	}
+	switch v := c.Query("folder"); v {
+	case "":
+	case "none":
+		q.where = append(q.where, "folder_id IS NULL")
+	default:
+		q.where = append(q.where, "folder_id = "+q.arg(v))
+	}
+	for _, p := range []struct{ name, op string }{{"created_from", ">="}, {"created_to", "<"}} {
+		v := c.Query(p.name)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			if t, err = time.Parse("2006-01-02", v); err != nil {
+				return nil, fmt.Errorf("%s must be an RFC 3339 timestamp or YYYY-MM-DD date", p.name)
+			}
+		}
+		q.where = append(q.where, fmt.Sprintf("created_at %s %s", p.op, q.arg(t.UTC())))
+	}
+	return q, nil
+}
+
+// countSQL returns the query counting all links matching the filters.
+func (q *linkListQuery) countSQL() string {
+	return "SELECT COUNT(*) FROM urls WHERE " + strings.Join(q.where, " AND ")
+}
+
+// pageSQL returns the query for one page, fetching one extra row to detect
+// whether another page follows. columns must not end with a comma.
+func (q *linkListQuery) pageSQL(columns string) string {
+	s := linkSorts[q.sort]
+	where := q.where
+	dir, cmp := "DESC", "<"
+	if !q.desc {
+		dir, cmp = "ASC", ">"
+	}
+	if q.cursor != nil {
+		where = append(where[:len(where):len(where)], fmt.Sprintf("(%s, id) %s (%s::%s, %s)",
+			s.expr, cmp, q.arg(q.cursor.Value), s.cast, q.arg(q.cursor.ID)))
+	}
+	return fmt.Sprintf(`SELECT %s, (%s)::text FROM urls WHERE %s ORDER BY %s %s, id %s LIMIT %d`,
+		columns, s.expr, strings.Join(where, " AND "), s.expr, dir, dir, q.limit+1)
+}
@@ -0,0 +1,34 @@
+package users
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func TestDecodeLinkCursor(t *testing.T) {
+	tests := []struct {
+		name    string
+		sort    string
+		cursor  string
+		wantErr bool
+	}{
+		{name: "timestamp", sort: "created_at", cursor: linkCursor{Value: "2025-01-02 03:04:05.123456", ID: "a"}.encode()},
+		{name: "whole-second timestamp", sort: "created_at", cursor: linkCursor{Value: "2025-01-02 03:04:05", ID: "a"}.encode()},
+		{name: "epoch", sort: "last_clicked_at", cursor: linkCursor{Value: "1970-01-01 00:00:00", ID: "a"}.encode()},
+		{name: "clicks", sort: "clicks", cursor: linkCursor{Value: "42", ID: "a"}.encode()},
+		{name: "tampered timestamp", sort: "created_at", cursor: linkCursor{Value: "yesterday", ID: "a"}.encode(), wantErr: true},
+		{name: "tampered clicks", sort: "clicks", cursor: linkCursor{Value: "1; DROP TABLE urls", ID: "a"}.encode(), wantErr: true},
+		{name: "cursor of another sort", sort: "clicks", cursor: linkCursor{Value: "2025-01-02 03:04:05", ID: "a"}.encode(), wantErr: true},
+		{name: "missing id", sort: "clicks", cursor: linkCursor{Value: "42"}.encode(), wantErr: true},
+		{name: "not base64", sort: "created_at", cursor: "%%%", wantErr: true},
+		{name: "not json", sort: "created_at", cursor: base64.RawURLEncoding.EncodeToString([]byte("v=1")), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := decodeLinkCursor(tt.cursor, tt.sort)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("decodeLinkCursor() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
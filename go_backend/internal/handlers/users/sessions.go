@@ -0,0 +1,82 @@
+package users
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"go_backend/internal/security"
+	"go_backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sessionResponse is the JSON shape of one entry in the sessions list.
+type sessionResponse struct {
+	ID         string              `json:"id"`
+	CreatedAt  time.Time           `json:"created_at"`
+	LastSeenAt time.Time           `json:"last_seen_at"`
+	IPAddress  string              `json:"ip_address"`
+	UserAgent  string              `json:"user_agent"`
+	Client     utils.UserAgentInfo `json:"client"`
+	Current    bool                `json:"current"`
+}
+
+// GetUserSessions lists the devices the user is logged in on, most recently
+// used first. The session making the request is flagged as current.
+//
+// Example request:
+//
+//	GET /api/user/sessions
+func GetUserSessions(c *gin.Context) {
+	userID := c.MustGet("userID").(string)
+	current := c.GetString("sessionID")
+
+	sessions, err := security.ListSessions(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load sessions"})
+		return
+	}
+
+	resp := make([]sessionResponse, 0, len(sessions))
+	for _, s := range sessions {
+		resp = append(resp, sessionResponse{
+			ID:         s.ID,
+			CreatedAt:  s.CreatedAt,
+			LastSeenAt: s.LastSeenAt,
+			IPAddress:  s.IP,
+			UserAgent:  s.UserAgent,
+			Client:     utils.ParseUserAgent(s.UserAgent),
+			Current:    s.ID == current,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sessions": resp})
+}
+
+// RevokeUserSession logs the user out of one session. Its access token is
+// rejected by AuthMiddleware from the next request and its refresh token can
+// no longer be used. Revoking the current session also clears its cookies.
+//
+// Example request:
+//
+//	DELETE /api/user/sessions/:id
+func RevokeUserSession(c *gin.Context) {
+	userID := c.MustGet("userID").(string)
+	sessionID := c.Param("id")
+
+	if err := security.RevokeUserSession(userID, sessionID); err != nil {
+		if errors.Is(err, security.ErrSessionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke session"})
+		return
+	}
+
+	if sessionID == c.GetString("sessionID") {
+		utils.ClearAuthCookies(c)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "session revoked"})
+}
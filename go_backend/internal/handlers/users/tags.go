@@ -0,0 +1,128 @@
+package users
+
+import (
+	"errors"
+	"net/http"
+
+	"go_backend/internal/collections"
+	"go_backend/internal/models"
+	"go_backend/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetTags lists the user's tags with the number of links carrying each.
+//
+// Example request:
+//
+//	GET /api/user/tags
+func GetTags(c *gin.Context) {
+	userID := c.MustGet("userID").(string)
+
+	tags, err := collections.ListTags(storage.GetPostgres(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load tags"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tags": tags})
+}
+
+// CreateTag adds a tag. Tags are also created implicitly when a link is
+// tagged with a new name.
+//
+// Example request:
+//
+//	POST /api/user/tags
+//	{"name": "campaign-2025"}
+//
+// Responses:
+//
+//	201 Created - tag created
+//	400 Bad Request - invalid name
+//	409 Conflict - a tag with this name exists
+//	500 Internal Server Error - DB failure
+func CreateTag(c *gin.Context) {
+	userID := c.MustGet("userID").(string)
+
+	name, ok := bindName(c)
+	if !ok {
+		return
+	}
+	tag, err := collections.CreateTag(storage.GetPostgres(), userID, name)
+	if err != nil {
+		respondCollectionError(c, err, "tag not found")
+		return
+	}
+
+	c.JSON(http.StatusCreated, tag)
+}
+
+// RenameTag changes the name of one of the user's tags.
+//
+// Example request:
+//
+//	PATCH /api/user/tags/:id
+//	{"name": "campaign-2026"}
+func RenameTag(c *gin.Context) {
+	userID := c.MustGet("userID").(string)
+
+	name, ok := bindName(c)
+	if !ok {
+		return
+	}
+	tag, err := collections.RenameTag(storage.GetPostgres(), userID, c.Param("id"), name)
+	if err != nil {
+		respondCollectionError(c, err, "tag not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, tag)
+}
+
+// DeleteTag removes a tag from all of the user's links and deletes it. The
+// links themselves are kept.
+//
+// Example request:
+//
+//	DELETE /api/user/tags/:id
+func DeleteTag(c *gin.Context) {
+	userID := c.MustGet("userID").(string)
+
+	if err := collections.DeleteTag(storage.GetPostgres(), userID, c.Param("id")); err != nil {
+		respondCollectionError(c, err, "tag not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "tag deleted"})
+}
+
+// bindName reads and normalizes the name of a tag or folder. It writes 400
+// and returns false if the name is missing or invalid.
+func bindName(c *gin.Context) (string, bool) {
+	var input models.NameInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return "", false
+	}
+	name, err := collections.NormalizeName(input.Name)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return "", false
+	}
+	return name, true
+}
+
+// respondCollectionError writes the response for an error from the
+// collections package: 404 with notFound, 409 for duplicate names and 500
+// otherwise.
+func respondCollectionError(c *gin.Context, err error, notFound string) {
+	switch {
+	case errors.Is(err, collections.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": notFound})
+	case errors.Is(err, collections.ErrNameTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server error"})
+	}
+}
@@ -0,0 +1,110 @@
+// Package mailer sends transactional email through a pluggable Mailer.
+//
+// The server installs an implementation once at startup with SetDefault
+// (usually FromEnv); handlers send through Default. Tests can install a
+// *Fake to inspect outgoing messages without a mail server.
+package mailer
+
+import (
+	"context"
+	"log"
+	"os"
+	"sync"
+)
+
+// Message is a single outgoing email.
+type Message struct {
+	To      string
+	Subject string
+	Text    string // plain-text body
+	HTML    string // optional HTML alternative
+}
+
+// Mailer delivers messages.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+var (
+	mu      sync.RWMutex
+	current Mailer = LogMailer{}
+)
+
+// Default returns the installed Mailer. Until SetDefault is called it is a
+// LogMailer.
+func Default() Mailer {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+// SetDefault installs m as the Mailer returned by Default.
+func SetDefault(m Mailer) {
+	mu.Lock()
+	defer mu.Unlock()
+	current = m
+}
+
+// FromEnv returns an SMTP mailer configured from SMTP_HOST, SMTP_PORT,
+// SMTP_EMAIL and SMTP_PASS, or a LogMailer when SMTP_HOST is not set.
+func FromEnv() Mailer {
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		log.Println("mailer: SMTP_HOST not set, emails will be logged instead of sent")
+		return LogMailer{}
+	}
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = "587"
+	}
+	return &SMTPMailer{
+		Host:     host,
+		Port:     port,
+		Username: os.Getenv("SMTP_EMAIL"),
+		Password: os.Getenv("SMTP_PASS"),
+		From:     os.Getenv("SMTP_EMAIL"),
+	}
+}
+
+// LogMailer writes messages to the log instead of sending them. It is meant
+// for local development only, since message bodies may contain secrets.
+type LogMailer struct{}
+
+// Send logs the message.
+func (LogMailer) Send(_ context.Context, msg Message) error {
+	log.Printf("mailer: to=%s subject=%q\n%s", msg.To, msg.Subject, msg.Text)
+	return nil
+}
+
+// Fake records messages in memory for tests.
+type Fake struct {
+	mu   sync.Mutex
+	sent []Message
+}
+
+// NewFake returns an empty Fake.
+func NewFake() *Fake {
+	return &Fake{}
+}
+
+// Send records the message.
+func (f *Fake) Send(_ context.Context, msg Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sent = append(f.sent, msg)
+	return nil
+}
+
+// Sent returns a copy of all recorded messages in send order.
+func (f *Fake) Sent() []Message {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Message(nil), f.sent...)
+}
+
+// Reset discards all recorded messages.
+func (f *Fake) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sent = nil
+}
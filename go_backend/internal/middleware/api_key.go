@@ -0,0 +1,117 @@
+// Package middleware provides reusable Gin middleware for authentication,
+// CORS handling, rate limiting, and request blocking.
+package middleware
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go_backend/internal/apikeys"
+	"go_backend/internal/security"
+	"go_backend/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Context keys set by APIKeyMiddleware.
+const (
+	apiKeyContextKey        = "apiKey"
+	apiKeyInvalidContextKey = "apiKeyInvalid"
+)
+
+// APIKeyMiddleware authenticates an API key presented as
+// "Authorization: Bearer sk_..." or "X-API-Key: sk_...". It runs globally
+// before rate limiting so valid keys are limited per key instead of per IP.
+// It never rejects a request itself: invalid keys are flagged and refused by
+// APIKeyOrAuthMiddleware, and still count against the client IP.
+func APIKeyMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		secret := apiKeyFromRequest(c.Request)
+		if secret == "" {
+			c.Next()
+			return
+		}
+
+		// Don't look up keys for clients that are already being limited.
+		if limited, retryAfter := security.IPRateLimited(c.Request); limited {
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+				"error":       "too many requests",
+				"retry_after": retryAfter,
+			})
+			return
+		}
+
+		key, err := apikeys.Authenticate(storage.GetPostgres(), secret)
+		switch {
+		case err == nil:
+			c.Set(apiKeyContextKey, key)
+		case errors.Is(err, apikeys.ErrInvalidKey):
+			c.Set(apiKeyInvalidContextKey, true)
+		default:
+			log.Printf("apikey: lookup failed: %v", err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "server error"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// APIKeyOrAuthMiddleware authenticates a route with either a valid API key
+// or, without one, the JWT checked by AuthMiddleware. API keys need the read
+// scope for GET and HEAD requests and the write scope for anything else.
+func APIKeyOrAuthMiddleware() gin.HandlerFunc {
+	jwtAuth := AuthMiddleware()
+	return func(c *gin.Context) {
+		if c.GetBool(apiKeyInvalidContextKey) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid api key"})
+			return
+		}
+
+		key, ok := apiKeyFromContext(c)
+		if !ok {
+			jwtAuth(c)
+			return
+		}
+
+		scope := apikeys.ScopeWrite
+		if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+			scope = apikeys.ScopeRead
+		}
+		if !key.Allows(scope) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "api key lacks the " + scope + " scope"})
+			return
+		}
+
+		c.Set("userID", key.UserID)
+		c.Set("apiKeyID", key.ID)
+		c.Next()
+	}
+}
+
+// apiKeyFromContext returns the key authenticated by APIKeyMiddleware.
+func apiKeyFromContext(c *gin.Context) (apikeys.Key, bool) {
+	v, ok := c.Get(apiKeyContextKey)
+	if !ok {
+		return apikeys.Key{}, false
+	}
+	key, ok := v.(apikeys.Key)
+	return key, ok
+}
+
+// apiKeyFromRequest extracts an API key from the X-API-Key header or from a
+// bearer token carrying the key prefix. JWT bearer tokens are left alone.
+func apiKeyFromRequest(r *http.Request) string {
+	if key := strings.TrimSpace(r.Header.Get("X-API-Key")); key != "" {
+		return key
+	}
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		if bearer = strings.TrimSpace(bearer); strings.HasPrefix(bearer, apikeys.Prefix) {
+			return bearer
+		}
+	}
+	return ""
+}
@@ -23,6 +23,8 @@ func init() {
 
 // AuthMiddleware validates the JWT token from a cookie or header
 // and ensures the user is authenticated before accessing protected routes.
+// Tokens of revoked sessions are rejected; for valid ones the session's
+// last-seen time is refreshed and its ID is stored as "sessionID".
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		claims, err := security.ValidateJWTFromCookie(c)
@@ -41,6 +43,8 @@ func AuthMiddleware() gin.HandlerFunc {
 
 		log.Printf("auth: JWT valid — userID=%s", claims.UserID)
 		c.Set("userID", claims.UserID)
+		c.Set("sessionID", claims.ID)
+		security.TouchSession(claims.ID, c.ClientIP())
 		c.Next()
 	}
 }
@@ -59,8 +63,8 @@ func CORSMiddleware() gin.HandlerFunc {
 		if origin == allowedOrigin || strings.Contains(origin, "localhost") {
 			c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
 			c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-			c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-			c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
+			c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key")
+			c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, PATCH, DELETE")
 		}
 
 		if c.Request.Method == http.MethodOptions {
@@ -73,10 +77,17 @@ func CORSMiddleware() gin.HandlerFunc {
 }
 
 // RateLimitMiddleware enforces a simple per-IP rate limit to
-// prevent abuse and excessive API requests.
+// prevent abuse and excessive API requests. Requests authenticated with an
+// API key by APIKeyMiddleware are limited per key instead.
 func RateLimitMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		allowed, retryAfter := security.AllowRequest(c.Request)
+		var allowed bool
+		var retryAfter int
+		if key, ok := apiKeyFromContext(c); ok {
+			allowed, retryAfter = security.AllowAPIKeyRequest(key.ID)
+		} else {
+			allowed, retryAfter = security.AllowRequest(c.Request)
+		}
 		if !allowed {
 			c.Header("Retry-After", strconv.Itoa(retryAfter))
 			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
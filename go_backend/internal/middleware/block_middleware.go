@@ -52,6 +52,12 @@ func BlockBadRequests() gin.HandlerFunc {
 			}
 		}
 
+		// Scripts using a valid API key may identify as curl, wget, etc.
+		if _, ok := apiKeyFromContext(c); ok {
+			c.Next()
+			return
+		}
+
 		blockedAgents := []string{"IbouBot", "bot@ibou.io", "curl", "wget", "python-requests"}
 		for _, bad := range blockedAgents {
 			if strings.Contains(strings.ToLower(ua), strings.ToLower(bad)) {
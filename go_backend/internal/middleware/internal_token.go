@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+)
+
+// internalTokenHeader carries INTERNAL_API_TOKEN on operator requests.
+const internalTokenHeader = "X-Internal-Token"
+
+// InternalTokenMiddleware restricts a route to operators presenting
+// INTERNAL_API_TOKEN in the X-Internal-Token header. Other requests, and all
+// requests while no token is configured, get 404 so that internal endpoints
+// are not advertised.
+func InternalTokenMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := os.Getenv("INTERNAL_API_TOKEN")
+		given := c.GetHeader(internalTokenHeader)
+		if token == "" || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
+		c.Next()
+	}
+}
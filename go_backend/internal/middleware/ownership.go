@@ -0,0 +1,27 @@
+// Package middleware provides reusable Gin middleware for authentication,
+// CORS handling, rate limiting, and request blocking.
+package middleware
+
+import (
+	"go_backend/internal/authz"
+	"go_backend/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireLinkOwner verifies that the link named by the :id path parameter
+// belongs to the authenticated user and stores it for handlers via
+// authz.LinkFromContext. It must run after AuthMiddleware. Links owned by
+// other users are reported as 404 so their existence is not revealed.
+func RequireLinkOwner() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := authz.UserID(c)
+		link, err := authz.OwnedLink(storage.GetPostgres(), c.Param("id"), userID)
+		if err != nil {
+			authz.AbortWithLinkError(c, err)
+			return
+		}
+		authz.SetLink(c, link)
+		c.Next()
+	}
+}
@@ -17,3 +17,48 @@ type LoginInput struct {
 	Password string `json:"password" binding:"required"`
 }
 
+
+// ForgotPasswordInput represents the expected JSON payload for requesting
+// a password reset link.
+type ForgotPasswordInput struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ResetPasswordInput represents the expected JSON payload for completing a
+// password reset with the token from the emailed link.
+type ResetPasswordInput struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=6"`
+}
+
+// VerifyEmailInput represents the expected JSON payload for confirming an
+// email address with the token from the emailed link.
+type VerifyEmailInput struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// ResendVerificationInput represents the expected JSON payload for
+// requesting a new verification email.
+type ResendVerificationInput struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ChangePasswordInput represents the expected JSON payload for changing the
+// password of a logged-in local user.
+type ChangePasswordInput struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,min=6"`
+}
+
+// RefreshInput is the optional JSON payload for refreshing a session when
+// the refresh token is not sent as a cookie.
+type RefreshInput struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// CreateAPIKeyInput represents the expected JSON payload for creating a
+// personal API key. Scopes default to read and write.
+type CreateAPIKeyInput struct {
+	Name   string   `json:"name" binding:"required,max=100"`
+	Scopes []string `json:"scopes"`
+}
@@ -17,11 +17,112 @@ type URL struct {
 	ClickCount    int        `json:"click_count"`               // Number of times the URL has been clicked
 	LastClicked   *time.Time `json:"last_clicked_at,omitempty"` // Last click timestamp (optional)
 	CreatedQRCode bool       `json:"created_qrcode"`            // Flag if QR code was generated for this URL
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`      // Time after which the URL stops redirecting (optional)
+	MaxClicks     *int       `json:"max_clicks,omitempty"`      // Redirect limit (optional)
+	ActiveFrom    *time.Time `json:"active_from,omitempty"`     // Time before which the URL does not redirect (optional)
+	FallbackURL   string     `json:"fallback_url,omitempty"`    // Destination once expired or exhausted (optional)
 }
 
 // URLRequest represents incoming request payload to create a new shortened URL.
 type URLRequest struct {
-	OriginalURL   string `json:"original_url" binding:"required"` // URL to shorten (required)
-	Slug          string `json:"slug"`                             // Optional custom slug
-	CreatedQRCode bool   `json:"created_qrcode"`                   // Flag to indicate QR code generation
+	OriginalURL    string       `json:"original_url" binding:"required"` // URL to shorten (required)
+	Slug           string       `json:"slug"`                            // Optional custom slug
+	CreatedQRCode  bool         `json:"created_qrcode"`                  // Flag to indicate QR code generation
+	ExpiresAt      *time.Time   `json:"expires_at"`                      // Optional expiry timestamp
+	MaxClicks      *int         `json:"max_clicks"`                      // Optional maximum number of redirects
+	ActiveFrom     *time.Time   `json:"active_from"`                     // Optional scheduled activation time
+	FallbackURL    string       `json:"fallback_url"`                    // Optional destination once expired or exhausted
+	Tags           []string     `json:"tags"`                            // Optional tag names; missing tags are created
+	FolderID       string       `json:"folder_id"`                       // Optional folder to file the link in
+	Password       string       `json:"password"`                        // Optional password visitors must enter
+	GeoRules       []GeoRule    `json:"geo_rules"`                       // Optional per-country destinations
+	DeviceRules    []DeviceRule `json:"device_rules"`                    // Optional per-device destinations
+	Variants       []Variant    `json:"variants"`                        // Optional weighted A/B destinations
+	StickyVariants bool         `json:"sticky_variants"`                 // Keep each visitor on one variant
+	UTM            *UTM         `json:"utm"`                             // Optional UTM tags added to original_url
+	ForwardQuery   bool         `json:"forward_query"`                   // Pass the short URL's query string on
+	QueryConflict  string       `json:"query_conflict"`                  // link (default), request or append
+}
+
+// UTM holds campaign tags merged into a link's destination as utm_* query
+// parameters when the link is created. Empty fields are left out.
+type UTM struct {
+	Source   string `json:"source"`
+	Medium   string `json:"medium"`
+	Campaign string `json:"campaign"`
+	Term     string `json:"term"`
+	Content  string `json:"content"`
+}
+
+// GeoRule sends visitors from any of Countries (ISO 3166-1 alpha-2 codes)
+// to URL instead of the link's original URL. Rules are tried in order.
+type GeoRule struct {
+	Countries []string `json:"countries"`
+	URL       string   `json:"url"`
+}
+
+// DeviceRule sends visitors whose User-Agent matches every given condition
+// to URL. Devices are desktop, mobile, tablet or bot; OS and Browsers use the
+// names reported in visit analytics, e.g. "iOS" or "Chrome". Bot, when set,
+// matches only crawlers (true) or only people (false). Rules are tried in
+// order.
+type DeviceRule struct {
+	Devices  []string `json:"devices,omitempty"`
+	OS       []string `json:"os,omitempty"`
+	Browsers []string `json:"browsers,omitempty"`
+	Bot      *bool    `json:"bot,omitempty"`
+	URL      string   `json:"url"`
+}
+
+// Variant is one destination of an A/B test. Visitors not matched by a
+// device or geo rule are split between variants in proportion to Weight
+// (1-1000); Name identifies the variant in visit analytics.
+type Variant struct {
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	Weight int    `json:"weight"`
+}
+
+// URLUpdateRequest represents a partial update of an existing shortened URL.
+// Nil fields are left unchanged; fields named in Clear are reset to NULL.
+type URLUpdateRequest struct {
+	ID             string        `json:"id" binding:"required"` // Shortlink to update (required)
+	OriginalURL    *string       `json:"original_url"`          // New destination URL
+	Slug           *string       `json:"slug"`                  // New slug (must be available)
+	ExpiresAt      *time.Time    `json:"expires_at"`            // New expiry timestamp
+	MaxClicks      *int          `json:"max_clicks"`            // New maximum number of redirects
+	ActiveFrom     *time.Time    `json:"active_from"`           // New scheduled activation time
+	FallbackURL    *string       `json:"fallback_url"`          // New destination once expired or exhausted
+	Tags           *[]string     `json:"tags"`                  // New tag names, replacing the current ones
+	FolderID       *string       `json:"folder_id"`             // New folder
+	Password       *string       `json:"password"`              // New access password
+	GeoRules       *[]GeoRule    `json:"geo_rules"`             // New per-country destinations
+	DeviceRules    *[]DeviceRule `json:"device_rules"`          // New per-device destinations
+	Variants       *[]Variant    `json:"variants"`              // New A/B destinations
+	StickyVariants *bool         `json:"sticky_variants"`       // New sticky assignment setting
+	ForwardQuery   *bool         `json:"forward_query"`         // New query string pass-through setting
+	QueryConflict  *string       `json:"query_conflict"`        // New conflict handling for forwarded queries
+	Clear          []string      `json:"clear"`                 // Optional settings to remove, e.g. ["expires_at"]
+}
+
+// QRCodeRequest represents the rendering options for a shortlink's QR code.
+// Zero values select the defaults noted below.
+type QRCodeRequest struct {
+	Size            int    `json:"size"`             // Image width and height in pixels (default 512)
+	ErrorCorrection string `json:"error_correction"` // L, M, Q or H (default M)
+	Margin          *int   `json:"margin"`           // Quiet zone in modules (default 4)
+	Foreground      string `json:"foreground"`       // Hex colour of dark modules (default #000000)
+	Background      string `json:"background"`       // Hex colour of light modules (default #ffffff)
+}
+
+// NameInput represents the JSON payload for creating or renaming a tag or
+// folder.
+type NameInput struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// LinkUnlockInput represents the password submitted to open a
+// password-protected link, as JSON or as a form field.
+type LinkUnlockInput struct {
+	Password string `json:"password" form:"password" binding:"required"`
 }
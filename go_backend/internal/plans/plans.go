@@ -0,0 +1,211 @@
+// Package plans defines per-plan entitlements and checks them against a
+// user's current usage.
+//
+// Handlers call the Check* functions before creating resources and respond
+// with http.StatusForbidden and the returned *LimitError as the JSON body.
+package plans
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"go_backend/internal/security"
+	"go_backend/internal/utils"
+)
+
+// Known plan names as stored in users.plan.
+const (
+	Free = "free"
+	Pro  = "pro"
+)
+
+// Unlimited marks a limit that is not enforced.
+const Unlimited = -1
+
+// Limit names reported in LimitError.Limit.
+const (
+	LimitTotalLinks    = "total_links"
+	LimitLinksPerMonth = "links_per_month"
+	LimitCustomSlugs   = "custom_slugs"
+	LimitQRCodes       = "qr_codes"
+
+	// LimitUnverifiedLinks caps the links of users who have not verified
+	// their email while EMAIL_VERIFICATION_MODE=limited.
+	LimitUnverifiedLinks = "unverified_links"
+)
+
+// Limits are the entitlements of a plan.
+type Limits struct {
+	Plan                   string `json:"plan"`
+	TotalLinks             int    `json:"total_links"`
+	LinksPerMonth          int    `json:"links_per_month"`
+	CustomSlugs            int    `json:"custom_slugs"`
+	QRCodes                int    `json:"qr_codes"`
+	AnalyticsRetentionDays int    `json:"analytics_retention_days"`
+}
+
+var limits = map[string]Limits{
+	Free: {
+		Plan:                   Free,
+		TotalLinks:             50,
+		LinksPerMonth:          20,
+		CustomSlugs:            5,
+		QRCodes:                10,
+		AnalyticsRetentionDays: 30,
+	},
+	Pro: {
+		Plan:                   Pro,
+		TotalLinks:             Unlimited,
+		LinksPerMonth:          Unlimited,
+		CustomSlugs:            Unlimited,
+		QRCodes:                Unlimited,
+		AnalyticsRetentionDays: 365,
+	},
+}
+
+// For returns the limits of a plan. Unknown plans get the free limits.
+func For(plan string) Limits {
+	if l, ok := limits[plan]; ok {
+		return l
+	}
+	return limits[Free]
+}
+
+// ForUser looks up the user's plan and returns its limits.
+func ForUser(db *sql.DB, userID string) (Limits, error) {
+	plan, err := utils.GetUserPlan(db, userID)
+	if err != nil {
+		return Limits{}, err
+	}
+	return For(plan), nil
+}
+
+// LimitError reports which plan limit a request would exceed. It is
+// designed to be returned to clients as the JSON response body.
+type LimitError struct {
+	Message string `json:"error"`
+	Limit   string `json:"limit"`
+	Plan    string `json:"plan"`
+	Max     int    `json:"max"`
+}
+
+func (e *LimitError) Error() string { return e.Message }
+
+func newLimitError(l Limits, limit string, max int) *LimitError {
+	return &LimitError{
+		Message: fmt.Sprintf("plan limit exceeded: %s (max %d on the %s plan)", limit, max, l.Plan),
+		Limit:   limit,
+		Plan:    l.Plan,
+		Max:     max,
+	}
+}
+
+// AsLimitError reports whether err is a *LimitError and returns it.
+func AsLimitError(err error) (*LimitError, bool) {
+	var le *LimitError
+	ok := errors.As(err, &le)
+	return le, ok
+}
+
+// CheckLinkCreation verifies that the user may create n more links, of which
+// customSlugs use a custom slug. It returns a *LimitError naming the first
+// exceeded limit, or another error if usage could not be read.
+func CheckLinkCreation(db *sql.DB, userID string, l Limits, n, customSlugs int) error {
+	if security.EmailVerificationMode() == security.VerificationLimited {
+		if err := checkUnverified(db, userID, l, n); err != nil {
+			return err
+		}
+	}
+
+	if l.TotalLinks != Unlimited {
+		ok, err := utils.CanUserShortenMore(db, userID, l.TotalLinks-n+1)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return newLimitError(l, LimitTotalLinks, l.TotalLinks)
+		}
+	}
+
+	if l.LinksPerMonth != Unlimited {
+		var count int
+		err := db.QueryRow(`
+			SELECT COUNT(*) FROM urls
+			WHERE user_id = $1 AND created_at >= date_trunc('month', NOW())`, userID).Scan(&count)
+		if err != nil {
+			return err
+		}
+		if count+n > l.LinksPerMonth {
+			return newLimitError(l, LimitLinksPerMonth, l.LinksPerMonth)
+		}
+	}
+
+	if customSlugs > 0 {
+		if err := CheckCustomSlugs(db, userID, l, customSlugs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkUnverified applies the unverified-email link cap, which sits below
+// the plan limits until the user verifies their address. OAuth users count
+// as verified even without email_verified_at.
+func checkUnverified(db *sql.DB, userID string, l Limits, n int) error {
+	var (
+		verified bool
+		count    int
+	)
+	err := db.QueryRow(`
+		SELECT u.email_verified_at IS NOT NULL OR u.provider <> 'local',
+		       (SELECT COUNT(*) FROM urls WHERE user_id = u.id)
+		FROM users u WHERE u.id = $1`, userID).Scan(&verified, &count)
+	if err != nil {
+		return err
+	}
+	if max := security.UnverifiedLinkLimit(); !verified && count+n > max {
+		le := newLimitError(l, LimitUnverifiedLinks, max)
+		le.Message = "verify your email address to create more links"
+		return le
+	}
+	return nil
+}
+
+// CheckCustomSlugs verifies that the user may add n more custom slugs.
+func CheckCustomSlugs(db *sql.DB, userID string, l Limits, n int) error {
+	if l.CustomSlugs == Unlimited {
+		return nil
+	}
+	var count int
+	err := db.QueryRow(`SELECT COUNT(*) FROM urls WHERE user_id = $1 AND custom_slug`, userID).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count+n > l.CustomSlugs {
+		return newLimitError(l, LimitCustomSlugs, l.CustomSlugs)
+	}
+	return nil
+}
+
+// CheckQRCode verifies that the user may create one more QR code.
+func CheckQRCode(db *sql.DB, userID string, l Limits) error {
+	if l.QRCodes == Unlimited {
+		return nil
+	}
+	var count int
+	err := db.QueryRow(`SELECT COUNT(*) FROM urls WHERE user_id = $1 AND created_qrcode`, userID).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count >= l.QRCodes {
+		return newLimitError(l, LimitQRCodes, l.QRCodes)
+	}
+	return nil
+}
+
+// RetentionStart returns the earliest visit time the plan may query.
+func (l Limits) RetentionStart(now time.Time) time.Time {
+	return now.AddDate(0, 0, -l.AnalyticsRetentionDays)
+}
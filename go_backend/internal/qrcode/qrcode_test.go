@@ -0,0 +1,154 @@
+package qrcode
+
+import (
+	"bytes"
+	"errors"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+// knownVector is "https://sho.rt/abc" at level M (version 2, mask 0) as
+// produced by two independent encoders; '#' marks a dark module.
+var knownVector = []string{
+	"#######..##.#.##..#######",
+	"#.....#.#.#....#..#.....#",
+	"#.###.#...#.#..##.#.###.#",
+	"#.###.#..###.#.#..#.###.#",
+	"#.###.#.########..#.###.#",
+	"#.....#...#...###.#.....#",
+	"#######.#.#.#.#.#.#######",
+	"...........#...#.........",
+	"#.#.#.#...##....#...#..#.",
+	"##..#....###.#..###.....#",
+	".#######......#....#..###",
+	"##.#.....#.####.##.#...#.",
+	"#.#.###.#.#...######.#.##",
+	".#.#...#.#.#..#..##..#..#",
+	"#.#.#######..#..##.#..###",
+	".#..##.#...#...##.#.#..#.",
+	"#...#.##..#.#...######...",
+	"........#..###..#...##.##",
+	"#######...###.###.#.##.##",
+	"#.....#..##.###.#...##.##",
+	"#.###.#.#.##..#.######..#",
+	"#.###.#..#.#..####.####..",
+	"#.###.#.##...#..#...#...#",
+	"#.....#..###....#.#.##.#.",
+	"#######.#.#.#..##..#...##",
+}
+
+func TestEncodeKnownVector(t *testing.T) {
+	c, err := Encode([]byte("https://sho.rt/abc"), Medium)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Version != 2 || c.Mask != 0 || c.Size != len(knownVector) {
+		t.Fatalf("Encode() version %d mask %d size %d, want version 2 mask 0 size %d",
+			c.Version, c.Mask, c.Size, len(knownVector))
+	}
+	for y, want := range knownVector {
+		var got strings.Builder
+		for x := 0; x < c.Size; x++ {
+			if c.Dark(x, y) {
+				got.WriteByte('#')
+			} else {
+				got.WriteByte('.')
+			}
+		}
+		if got.String() != want {
+			t.Errorf("row %2d = %s, want %s", y, got.String(), want)
+		}
+	}
+}
+
+func TestEncodeVersion(t *testing.T) {
+	tests := []struct {
+		length  int
+		level   Level
+		version int
+		wantErr error
+	}{
+		{length: 17, level: Low, version: 1},
+		{length: 18, level: Low, version: 2},
+		{length: 14, level: Medium, version: 1},
+		{length: 15, level: Medium, version: 2},
+		{length: 7, level: High, version: 1},
+		{length: 2953, level: Low, version: 40},
+		{length: 2954, level: Low, wantErr: ErrDataTooLong},
+	}
+	for _, tt := range tests {
+		c, err := Encode(bytes.Repeat([]byte("a"), tt.length), tt.level)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("Encode(%d bytes, %s) error = %v, want %v", tt.length, tt.level, err, tt.wantErr)
+			continue
+		}
+		if err == nil && c.Version != tt.version {
+			t.Errorf("Encode(%d bytes, %s) version = %d, want %d", tt.length, tt.level, c.Version, tt.version)
+		}
+	}
+}
+
+func TestPNG(t *testing.T) {
+	c, err := Encode([]byte("https://sho.rt/abc"), Medium)
+	if err != nil {
+		t.Fatal(err)
+	}
+	black := color.NRGBA{A: 0xff}
+	white := color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+
+	// 25 modules plus a 4-module quiet zone on each side at 10px a module.
+	data, err := c.PNG(RenderOptions{Size: 330, Margin: 4, Foreground: black, Background: white})
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 330 || b.Dy() != 330 {
+		t.Fatalf("PNG size = %v, want 330x330", b)
+	}
+	for y, row := range knownVector {
+		for x := range row {
+			want := white
+			if row[x] == '#' {
+				want = black
+			}
+			got := color.NRGBAModel.Convert(img.At(45+10*x, 45+10*y)).(color.NRGBA)
+			if got != want {
+				t.Fatalf("module (%d,%d) = %v, want %v", x, y, got, want)
+			}
+		}
+	}
+
+	if _, err := c.PNG(RenderOptions{Size: 32, Margin: 4}); !errors.Is(err, ErrSizeTooSmall) {
+		t.Errorf("PNG() with size 32 error = %v, want %v", err, ErrSizeTooSmall)
+	}
+}
+
+func TestParseHexColor(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    color.NRGBA
+		wantErr bool
+	}{
+		{in: "#000", want: color.NRGBA{A: 0xff}},
+		{in: "fff", want: color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}},
+		{in: "#1a2b3c", want: color.NRGBA{R: 0x1a, G: 0x2b, B: 0x3c, A: 0xff}},
+		{in: "#1a2b3c80", want: color.NRGBA{R: 0x1a, G: 0x2b, B: 0x3c, A: 0x80}},
+		{in: "#12345", wantErr: true},
+		{in: "#gggggg", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseHexColor(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseHexColor(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseHexColor(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package qrcode
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strconv"
+	"strings"
+)
+
+// RenderOptions controls how a Code is drawn.
+type RenderOptions struct {
+	Size       int         // output width and height in pixels
+	Margin     int         // quiet zone width in modules (the standard asks for 4)
+	Foreground color.NRGBA // colour of dark modules
+	Background color.NRGBA // colour of light modules and the quiet zone
+}
+
+// ErrSizeTooSmall is returned when the requested size cannot give every
+// module at least one pixel.
+var ErrSizeTooSmall = errors.New("qrcode: size too small for this symbol")
+
+// PNG renders the code as a two-colour PNG of exactly opts.Size pixels.
+func (c *Code) PNG(opts RenderOptions) ([]byte, error) {
+	total := c.Size + 2*opts.Margin
+	if opts.Size < total {
+		return nil, ErrSizeTooSmall
+	}
+
+	palette := color.Palette{opts.Background, opts.Foreground}
+	img := image.NewPaletted(image.Rect(0, 0, opts.Size, opts.Size), palette)
+
+	// Map each pixel to a module; modules differ by at most one pixel in
+	// width when Size is not a multiple of the module count.
+	for py := 0; py < opts.Size; py++ {
+		my := py*total/opts.Size - opts.Margin
+		row := img.Pix[py*img.Stride : py*img.Stride+opts.Size]
+		for px := range row {
+			mx := px*total/opts.Size - opts.Margin
+			if c.Dark(mx, my) {
+				row[px] = 1
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	enc := png.Encoder{CompressionLevel: png.BestCompression}
+	if err := enc.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// SVG renders the code as a scalable SVG document whose width and height
+// are opts.Size. Each module is one unit of the view box.
+func (c *Code) SVG(opts RenderOptions) []byte {
+	total := c.Size + 2*opts.Margin
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `<svg xmlns="http://www.w3.org/2000/svg" version="1.1" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		opts.Size, opts.Size, total, total)
+	fmt.Fprintf(&sb, `<rect width="100%%" height="100%%" fill="%s"%s/>`,
+		hexRGB(opts.Background), opacityAttr(opts.Background))
+	fmt.Fprintf(&sb, `<path fill="%s"%s d="`, hexRGB(opts.Foreground), opacityAttr(opts.Foreground))
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if c.modules[y][x] {
+				fmt.Fprintf(&sb, "M%d,%dh1v1h-1z", x+opts.Margin, y+opts.Margin)
+			}
+		}
+	}
+	sb.WriteString(`"/></svg>`)
+	return []byte(sb.String())
+}
+
+// ParseHexColor parses "#RGB", "#RRGGBB" or "#RRGGBBAA" (the "#" is optional).
+func ParseHexColor(s string) (color.NRGBA, error) {
+	s = strings.TrimPrefix(s, "#")
+	if len(s) == 3 {
+		s = string([]byte{s[0], s[0], s[1], s[1], s[2], s[2]})
+	}
+	if len(s) == 6 {
+		s += "ff"
+	}
+	if len(s) != 8 {
+		return color.NRGBA{}, fmt.Errorf("qrcode: invalid colour %q", s)
+	}
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return color.NRGBA{}, fmt.Errorf("qrcode: invalid colour %q", s)
+	}
+	return color.NRGBA{R: uint8(v >> 24), G: uint8(v >> 16), B: uint8(v >> 8), A: uint8(v)}, nil
+}
+
+// hexRGB formats the colour as "#rrggbb".
+func hexRGB(c color.NRGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// opacityAttr returns a fill-opacity attribute for translucent colours.
+func opacityAttr(c color.NRGBA) string {
+	if c.A == 0xff {
+		return ""
+	}
+	return fmt.Sprintf(` fill-opacity="%.3f"`, float64(c.A)/255)
+}
@@ -0,0 +1,255 @@
+package security
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// JWT signing keys are configured with:
+//
+//	JWT_KEYS="2025-01=/etc/shortly/jwt-2025-01.pem,2024-07=/etc/shortly/jwt-2024-07.pub.pem"
+//	JWT_ACTIVE_KID="2025-01"
+//
+// Each entry maps a key ID (kid) to a PEM file holding an RSA or Ed25519
+// private key (PKCS#8, or PKCS#1 for RSA) or a public key (PKIX). New tokens
+// are signed with the active key and carry its kid; tokens signed by any other
+// listed key still verify, so a key can be rotated out by first demoting it
+// to a public-key entry and removing it once its tokens have expired.
+//
+// Without JWT_KEYS, tokens are signed with HS256 and JWT_SECRET as before.
+// JWT_SECRET, when set, keeps verifying HS256 tokens without a kid, which
+// lets existing sessions survive the switch to asymmetric keys.
+//
+// Tokens that are not JWTs, such as email verification links and link
+// unlock cookies, are signed with TOKEN_HMAC_SECRET, or with JWT_SECRET when
+// it is unset. One of the two is always required.
+
+// jwtKey is one entry of the key ring.
+type jwtKey struct {
+	kid     string
+	method  jwt.SigningMethod
+	private crypto.Signer // nil for verification-only keys
+	public  crypto.PublicKey
+}
+
+// keyRing holds every key tokens may be signed or verified with.
+type keyRing struct {
+	active *jwtKey
+	byKID  map[string]*jwtKey
+	secret []byte
+	hmac   []byte // signs tokens that are not JWTs
+}
+
+var (
+	ringOnce sync.Once
+	ring     *keyRing
+	ringErr  error
+)
+
+// LoadKeys reads the key ring from the environment. The server calls it at
+// startup so that a misconfiguration stops it before any request is served.
+// Later calls return the result of the first.
+func LoadKeys() error {
+	ringOnce.Do(func() {
+		ring, ringErr = loadKeyRing(os.Getenv("JWT_KEYS"), os.Getenv("JWT_ACTIVE_KID"),
+			os.Getenv("JWT_SECRET"), os.Getenv("TOKEN_HMAC_SECRET"))
+		if ringErr != nil {
+			ringErr = fmt.Errorf("jwt keys: %w", ringErr)
+		}
+	})
+	return ringErr
+}
+
+// keys returns the key ring, loading it if LoadKeys has not been called.
+// It panics on misconfiguration, since no token can be issued or verified
+// without keys.
+func keys() *keyRing {
+	if err := LoadKeys(); err != nil {
+		panic(err.Error())
+	}
+	return ring
+}
+
+func loadKeyRing(spec, activeKID, secret, hmacSecret string) (*keyRing, error) {
+	r := &keyRing{byKID: map[string]*jwtKey{}}
+	if secret != "" {
+		r.secret = []byte(secret)
+	}
+	switch {
+	case hmacSecret != "":
+		r.hmac = []byte(hmacSecret)
+	case secret != "":
+		r.hmac = r.secret
+	default:
+		return nil, errors.New("TOKEN_HMAC_SECRET or JWT_SECRET must be set")
+	}
+
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		kid, path, ok := strings.Cut(entry, "=")
+		if !ok || kid == "" || path == "" {
+			return nil, fmt.Errorf("invalid JWT_KEYS entry %q, want kid=path", entry)
+		}
+		pemBytes, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		k, err := parseKey(kid, pemBytes)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", kid, err)
+		}
+		r.byKID[kid] = k
+	}
+
+	switch {
+	case activeKID != "":
+		k, ok := r.byKID[activeKID]
+		if !ok {
+			return nil, fmt.Errorf("JWT_ACTIVE_KID %q is not listed in JWT_KEYS", activeKID)
+		}
+		if k.private == nil {
+			return nil, fmt.Errorf("JWT_ACTIVE_KID %q has no private key", activeKID)
+		}
+		r.active = k
+	case len(r.byKID) > 0:
+		return nil, errors.New("JWT_ACTIVE_KID must be set when JWT_KEYS is used")
+	case r.secret == nil:
+		return nil, errors.New("JWT_SECRET is not set in environment variables")
+	}
+	return r, nil
+}
+
+// parseKey decodes a PEM private or public key into a ring entry.
+func parseKey(kid string, pemBytes []byte) (*jwtKey, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var parsed interface{}
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	k := &jwtKey{kid: kid}
+	switch key := parsed.(type) {
+	case *rsa.PrivateKey:
+		k.method, k.private, k.public = jwt.SigningMethodRS256, key, &key.PublicKey
+	case *rsa.PublicKey:
+		k.method, k.public = jwt.SigningMethodRS256, key
+	case ed25519.PrivateKey:
+		k.method, k.private, k.public = jwt.SigningMethodEdDSA, key, key.Public()
+	case ed25519.PublicKey:
+		k.method, k.public = jwt.SigningMethodEdDSA, key
+	default:
+		return nil, fmt.Errorf("unsupported key type %T, want RSA or Ed25519", parsed)
+	}
+	return k, nil
+}
+
+// signToken signs claims with the active key, or with JWT_SECRET when no
+// asymmetric key is configured.
+func signToken(claims jwt.Claims) (string, error) {
+	r := keys()
+	if r.active == nil {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(r.secret)
+	}
+	token := jwt.NewWithClaims(r.active.method, claims)
+	token.Header["kid"] = r.active.kid
+	return token.SignedString(r.active.private)
+}
+
+// verificationKey is the jwt.Keyfunc for parsing: it picks the key named by
+// the token's kid and refuses tokens whose algorithm does not match it.
+func verificationKey(token *jwt.Token) (interface{}, error) {
+	r := keys()
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok && r.secret != nil {
+			return r.secret, nil
+		}
+		return nil, errors.New("token has no kid")
+	}
+
+	k, ok := r.byKID[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown kid %q", kid)
+	}
+	if token.Method.Alg() != k.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %s for kid %q", token.Method.Alg(), kid)
+	}
+	return k.public, nil
+}
+
+// hmacSecret returns the key for the HMAC-signed tokens that are not JWTs,
+// such as email verification links.
+func hmacSecret() []byte {
+	return keys().hmac
+}
+
+// JWK is a public key in JSON Web Key form (RFC 7517).
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKSet is the document served at /.well-known/jwks.json.
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public halves of all asymmetric keys, active and
+// verification-only, ordered by kid. HS256 secrets are never published.
+func JWKS() JWKSet {
+	r := keys()
+	set := JWKSet{Keys: []JWK{}}
+	for _, k := range r.byKID {
+		jwk := JWK{Kid: k.kid, Use: "sig", Alg: k.method.Alg()}
+		switch pub := k.public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid < set.Keys[j].Kid })
+	return set
+}
@@ -0,0 +1,64 @@
+package security
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeEd25519Key writes a fresh PKCS#8 Ed25519 private key and returns its
+// path.
+func writeEd25519Key(t *testing.T) string {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "jwt.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadKeyRing(t *testing.T) {
+	spec := "k1=" + writeEd25519Key(t)
+	tests := []struct {
+		name       string
+		spec       string
+		activeKID  string
+		secret     string
+		hmacSecret string
+		wantHMAC   string
+		wantErr    bool
+	}{
+		{name: "jwt secret only", secret: "s", wantHMAC: "s"},
+		{name: "dedicated hmac secret", secret: "s", hmacSecret: "h", wantHMAC: "h"},
+		{name: "keys with hmac secret", spec: spec, activeKID: "k1", hmacSecret: "h", wantHMAC: "h"},
+		{name: "keys with jwt secret", spec: spec, activeKID: "k1", secret: "s", wantHMAC: "s"},
+		{name: "keys without any secret", spec: spec, activeKID: "k1", wantErr: true},
+		{name: "keys without active kid", spec: spec, secret: "s", wantErr: true},
+		{name: "hmac secret only", hmacSecret: "h", wantErr: true},
+		{name: "nothing", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := loadKeyRing(tt.spec, tt.activeKID, tt.secret, tt.hmacSecret)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadKeyRing() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !bytes.Equal(r.hmac, []byte(tt.wantHMAC)) {
+				t.Errorf("hmac secret = %q, want %q", r.hmac, tt.wantHMAC)
+			}
+		})
+	}
+}
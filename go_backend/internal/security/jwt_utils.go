@@ -4,7 +4,6 @@ package security
 import (
 	"errors"
 	"net/http"
-	"os"
 	"strings"
 	"time"
 
@@ -17,25 +16,34 @@ type JWTClaim struct {
 	jwt.RegisteredClaims
 }
 
-//! Generates the JWT token
-func GenerateJWT(userID string) (string, error) {
-	expirationTime := time.Now().Add(30 * 24 * time.Hour) // 30 days
+// AccessTokenTTL is how long an access JWT stays valid. Clients renew it
+// with the refresh token (see RefreshSession).
+func AccessTokenTTL() time.Duration {
+	return time.Duration(mustGetEnvInt("ACCESS_TOKEN_TTL_MINUTES", 15)) * time.Minute
+}
+
+//! Generates a short-lived access JWT for an existing session (jti)
+func GenerateJWT(userID, sessionID string) (string, time.Time, error) {
+	now := time.Now()
+	expirationTime := now.Add(AccessTokenTTL())
 	claims := &JWTClaim{
 		UserID: userID,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        sessionID,
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			IssuedAt:  jwt.NewNumericDate(now),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	jwtKey := getJWTKey()
-	return token.SignedString(jwtKey)
+	signed, err := signToken(claims)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return signed, expirationTime, nil
 }
 
 //! Validates JWT from *http.Request (Cookie or Bearer Header)
 func ValidateJWT(r *http.Request) (*JWTClaim, error) {
-	jwtKey := getJWTKey()
 	var tokenStr string
 
 	// 1️⃣ Try cookie
@@ -50,20 +58,7 @@ func ValidateJWT(r *http.Request) (*JWTClaim, error) {
 		tokenStr = strings.TrimPrefix(authHeader, "Bearer ")
 	}
 
-	claims := &JWTClaim{}
-	token, err := jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
-		return jwtKey, nil
-	})
-	if err != nil || !token.Valid {
-		return nil, errors.New("invalid or malformed JWT")
-	}
-
-	// 3️⃣ Check expiration explicitly (important!)
-	if claims.ExpiresAt == nil || claims.ExpiresAt.Before(time.Now()) {
-		return nil, errors.New("token has expired")
-	}
-
-	return claims, nil
+	return ValidateJWTFromString(tokenStr)
 }
 
 //! For Gin context (calls ValidateJWT under the hood)
@@ -72,31 +67,26 @@ func ValidateJWTFromCookie(c *gin.Context) (*JWTClaim, error) {
 	return ValidateJWT(c.Request)
 }
 
-//! Helper to load JWT key safely
-func getJWTKey() []byte {
-	key := os.Getenv("JWT_SECRET")
-	if key == "" {
-		panic("JWT_SECRET is not set in environment variables")
-	}
-	return []byte(key)
-}
-
 //! Validates JWT from a raw token string (used when token is not in request)
 func ValidateJWTFromString(tokenStr string) (*JWTClaim, error) {
-	jwtKey := getJWTKey()
 	claims := &JWTClaim{}
 
-	token, err := jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
-		return jwtKey, nil
-	})
+	// The key is chosen by kid and must match the token's algorithm
+	token, err := jwt.ParseWithClaims(tokenStr, claims, verificationKey)
 
 	if err != nil || !token.Valid {
 		return nil, errors.New("invalid or malformed JWT")
 	}
 
+	// Check expiration explicitly (important!)
 	if claims.ExpiresAt == nil || claims.ExpiresAt.Before(time.Now()) {
 		return nil, errors.New("token has expired")
 	}
 
+	// Reject tokens whose session was revoked (logout, password change)
+	if !sessionActive(claims.ID, claims.UserID) {
+		return nil, errors.New("token has been revoked")
+	}
+
 	return claims, nil
 }
@@ -0,0 +1,71 @@
+package security
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Settings for password-protected links.
+var (
+	linkUnlockTTL         = time.Duration(mustGetEnvInt("LINK_UNLOCK_TTL_MINUTES", 60)) * time.Minute
+	maxLinkUnlockAttempts = mustGetEnvInt("LINK_UNLOCK_RATE_LIMIT_MAX", 10) // per slug and minute
+	linkUnlockKeyPrefix   = rateLimitKeyPrefix + "unlock:"
+)
+
+// LinkUnlockTTL returns how long a visitor who entered a link's password
+// may open the link without entering it again.
+func LinkUnlockTTL() time.Duration {
+	return linkUnlockTTL
+}
+
+// AllowLinkUnlockAttempt limits password guesses on a protected link. Each
+// client gets a fifth of the per-slug budget, so a single client cannot use
+// it up, but five clients together can: the link then stays locked for
+// everyone, its owner included, until the minute is over. That is the price
+// of capping guesses spread over many addresses; letting a correct password
+// through a spent budget would leave those guesses uncapped.
+//
+// ip must be the client address resolved through the trusted proxies (gin's
+// Context.ClientIP), never a raw X-Forwarded-For value, which a guesser
+// could vary to get a fresh budget.
+func AllowLinkUnlockAttempt(ip, slug string) (bool, int) {
+	perClient := maxLinkUnlockAttempts / 5
+	if perClient < 1 {
+		perClient = 1
+	}
+	if ok, retry := allow(linkUnlockKeyPrefix+slug+":"+ip, perClient); !ok {
+		return false, retry
+	}
+	return allow(linkUnlockKeyPrefix+slug, maxLinkUnlockAttempts)
+}
+
+// GenerateLinkUnlockToken returns a signed token that unlocks the link with
+// linkID until the returned time.
+func GenerateLinkUnlockToken(linkID string) (string, time.Time) {
+	exp := time.Now().Add(linkUnlockTTL).Truncate(time.Second)
+	expStr := strconv.FormatInt(exp.Unix(), 10)
+	return expStr + "." + signLinkUnlock(linkID, expStr), exp
+}
+
+// ValidLinkUnlockToken reports whether token was issued for linkID and has
+// not expired.
+func ValidLinkUnlockToken(token, linkID string) bool {
+	expStr, sig, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(signLinkUnlock(linkID, expStr))) {
+		return false
+	}
+	exp, err := strconv.ParseInt(expStr, 10, 64)
+	return err == nil && time.Now().Unix() < exp
+}
+
+// signLinkUnlock returns the base64url HMAC-SHA256 of linkID and exp, keyed
+// with a purpose-specific derivation of the JWT secret.
+func signLinkUnlock(linkID, exp string) string {
+	mac := hmac.New(sha256.New, append([]byte("link-unlock:"), hmacSecret()...))
+	mac.Write([]byte(linkID + "\n" + exp))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
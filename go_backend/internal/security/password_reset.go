@@ -0,0 +1,77 @@
+package security
+
+import (
+	"errors"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"go_backend/internal/storage"
+)
+
+// PasswordResetTTL is how long a password reset link stays valid.
+const PasswordResetTTL = 30 * time.Minute
+
+const (
+	resetTokenKeyPrefix = "pwreset:"
+	resetUserKeyPrefix  = "pwreset_user:"
+)
+
+// ErrInvalidResetToken is returned for unknown, expired or used reset tokens.
+var ErrInvalidResetToken = errors.New("invalid or expired reset token")
+
+// IssuePasswordResetToken creates a single-use reset token for userID and
+// invalidates any token previously issued to the same user.
+func IssuePasswordResetToken(userID string) (string, error) {
+	token, hash, err := NewOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	client := storage.RedisClient
+	prev, err := client.Get(ctx, resetUserKeyPrefix+userID).Result()
+	if err != nil && err != redis.Nil {
+		return "", err
+	}
+
+	pipe := client.TxPipeline()
+	if prev != "" {
+		pipe.Del(ctx, resetTokenKeyPrefix+prev)
+	}
+	pipe.Set(ctx, resetTokenKeyPrefix+hash, userID, PasswordResetTTL)
+	pipe.Set(ctx, resetUserKeyPrefix+userID, hash, PasswordResetTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// PasswordResetUser returns the user a reset token was issued to without
+// using the token up, so it can be checked before the new password is stored.
+func PasswordResetUser(token string) (string, error) {
+	if token == "" {
+		return "", ErrInvalidResetToken
+	}
+	userID, err := storage.RedisClient.Get(ctx, resetTokenKeyPrefix+HashToken(token)).Result()
+	if err == redis.Nil {
+		return "", ErrInvalidResetToken
+	}
+	return userID, err
+}
+
+// ConsumePasswordResetToken atomically deletes the token and returns the
+// user it was issued to, so each token can be used at most once.
+func ConsumePasswordResetToken(token string) (string, error) {
+	if token == "" {
+		return "", ErrInvalidResetToken
+	}
+	hash := HashToken(token)
+	userID, err := storage.RedisClient.GetDel(ctx, resetTokenKeyPrefix+hash).Result()
+	if err == redis.Nil {
+		return "", ErrInvalidResetToken
+	}
+	if err != nil {
+		return "", err
+	}
+	_ = storage.RedisClient.Del(ctx, resetUserKeyPrefix+userID).Err()
+	return userID, nil
+}
@@ -20,7 +20,8 @@ var ctx = storage.Ctx
 // Configuration constants and defaults.
 var (
 	rateLimitKeyPrefix = "ratelimit:"
-	maxRequests        = mustGetEnvInt("RATE_LIMIT_MAX", 30) // 30 requests/minute/IP
+	maxRequests        = mustGetEnvInt("RATE_LIMIT_MAX", 30)          // 30 requests/minute/IP
+	maxAPIKeyRequests  = mustGetEnvInt("API_KEY_RATE_LIMIT_MAX", 120) // per key
 	rateLimitWindow    = time.Minute
 )
 
@@ -30,9 +31,34 @@ var (
 // If Redis is unavailable, the function fails open (allows the request)
 // to avoid denying legitimate traffic during infrastructure issues.
 func AllowRequest(r *http.Request) (bool, int) {
-	ip := clientIP(r)
-	key := rateLimitKeyPrefix + ip
+	return allow(rateLimitKeyPrefix+clientIP(r), maxRequests)
+}
+
+// AllowAPIKeyRequest enforces the per-key rate limit for requests
+// authenticated with an API key, in place of the per-IP limit.
+func AllowAPIKeyRequest(keyID string) (bool, int) {
+	return allow(rateLimitKeyPrefix+"key:"+keyID, maxAPIKeyRequests)
+}
 
+// IPRateLimited reports whether the client IP has already used up its
+// budget, without counting the request. It guards work done before the
+// limiter runs, such as looking up presented API keys.
+func IPRateLimited(r *http.Request) (bool, int) {
+	client := storage.RedisClient
+	if client == nil {
+		return false, 0
+	}
+	key := rateLimitKeyPrefix + clientIP(r)
+	count, err := client.Get(ctx, key).Int()
+	if err != nil || count < maxRequests {
+		return false, 0
+	}
+	return true, retryAfter(key)
+}
+
+// allow counts a request against key and reports whether it is within max
+// requests per rateLimitWindow.
+func allow(key string, max int) (bool, int) {
 	client := storage.RedisClient
 	if client == nil {
 		// Redis not initialized; allow by default
@@ -44,12 +70,8 @@ func AllowRequest(r *http.Request) (bool, int) {
 		return true, 0 // fail open on Redis error
 	}
 
-	if count >= maxRequests {
-		ttl, err := client.TTL(ctx, key).Result()
-		if err != nil || ttl < 0 {
-			return false, 60 // fallback retry window
-		}
-		return false, int(ttl.Seconds())
+	if count >= max {
+		return false, retryAfter(key)
 	}
 
 	pipe := client.TxPipeline()
@@ -63,6 +85,15 @@ func AllowRequest(r *http.Request) (bool, int) {
 	return true, 0
 }
 
+// retryAfter returns the seconds until the window of key resets.
+func retryAfter(key string) int {
+	ttl, err := storage.RedisClient.TTL(ctx, key).Result()
+	if err != nil || ttl < 0 {
+		return 60 // fallback retry window
+	}
+	return int(ttl.Seconds())
+}
+
 // clientIP extracts the real client IP address from the request,
 // respecting the X-Forwarded-For header when behind a proxy.
 func clientIP(r *http.Request) string {
@@ -0,0 +1,352 @@
+package security
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
+	"go_backend/internal/storage"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+// Session registry layout in Redis:
+//
+//	session:<sid>        -> hash {user_id, refresh, created_at, last_seen, ip, user_agent},
+//	                        expiring with the refresh token
+//	user_sessions:<uid>  -> set of the user's sids
+//	refresh:<hash>       -> sid, for the current and every rotated refresh token
+//	session_seen:<sid>   -> throttle marker for last_seen updates
+//
+// An access token is only accepted while its session exists, so deleting the
+// session revokes it before it expires. The session stores the hash of the
+// one refresh token that may still be used; presenting an older one means it
+// was stolen, and the whole session is revoked.
+const (
+	sessionKeyPrefix      = "session:"
+	userSessionsKeyPrefix = "user_sessions:"
+	refreshKeyPrefix      = "refresh:"
+	sessionSeenKeyPrefix  = "session_seen:"
+)
+
+// lastSeenInterval throttles last_seen writes to one per session per
+// interval, so authenticated requests do not each write to Redis.
+const lastSeenInterval = time.Minute
+
+var (
+	// ErrInvalidRefreshToken is returned for unknown or expired refresh
+	// tokens and for tokens whose session was revoked.
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	// ErrRefreshTokenReused is returned when an already rotated refresh
+	// token is presented. The session has been revoked.
+	ErrRefreshTokenReused = errors.New("refresh token reuse detected")
+	// ErrSessionNotFound is returned when a session does not exist or
+	// belongs to another user.
+	ErrSessionNotFound = errors.New("session not found")
+)
+
+// SessionMeta describes the client a session was started or used from.
+type SessionMeta struct {
+	IP        string
+	UserAgent string
+}
+
+// Session is an active login as listed to its owner.
+type Session struct {
+	ID         string
+	UserID     string
+	IP         string
+	UserAgent  string
+	CreatedAt  time.Time
+	LastSeenAt time.Time
+}
+
+// TokenPair is the result of logging in or refreshing a session.
+type TokenPair struct {
+	SessionID        string
+	AccessToken      string
+	AccessExpiresAt  time.Time
+	RefreshToken     string
+	RefreshExpiresAt time.Time
+}
+
+// RefreshTokenTTL is how long a session survives without being refreshed.
+func RefreshTokenTTL() time.Duration {
+	return time.Duration(mustGetEnvInt("REFRESH_TOKEN_TTL_DAYS", 30)) * 24 * time.Hour
+}
+
+// NewSession registers a session for userID and issues its first token pair.
+func NewSession(userID string, meta SessionMeta) (*TokenPair, error) {
+	refresh, hash, err := NewOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	sid := uuid.NewString()
+	ttl := RefreshTokenTTL()
+	key := sessionKeyPrefix + sid
+	setKey := userSessionsKeyPrefix + userID
+
+	pipe := storage.RedisClient.TxPipeline()
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	pipe.HSet(ctx, key,
+		"user_id", userID,
+		"refresh", hash,
+		"created_at", now,
+		"last_seen", now,
+		"ip", meta.IP,
+		"user_agent", meta.UserAgent,
+	)
+	pipe.Expire(ctx, key, ttl)
+	pipe.SAdd(ctx, setKey, sid)
+	pipe.Expire(ctx, setKey, ttl)
+	pipe.Set(ctx, refreshKeyPrefix+hash, sid, ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	return issuePair(userID, sid, refresh, ttl)
+}
+
+// RefreshSession rotates a refresh token: the presented token is retired and
+// a new pair is issued for the same session. Presenting a retired token
+// revokes the session and returns ErrRefreshTokenReused.
+func RefreshSession(refreshToken string, meta SessionMeta) (*TokenPair, error) {
+	hash := HashToken(refreshToken)
+	sid, err := storage.RedisClient.Get(ctx, refreshKeyPrefix+hash).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	next, nextHash, err := NewOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := RefreshTokenTTL()
+	key := sessionKeyPrefix + sid
+	var userID string
+
+	// WATCH the session so two concurrent refreshes cannot both rotate the
+	// same token.
+	err = storage.RedisClient.Watch(ctx, func(tx *redis.Tx) error {
+		vals, err := tx.HGetAll(ctx, key).Result()
+		if err != nil {
+			return err
+		}
+		if len(vals) == 0 {
+			return ErrInvalidRefreshToken
+		}
+		userID = vals["user_id"]
+		if vals["refresh"] != hash {
+			return ErrRefreshTokenReused
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.HSet(ctx, key,
+				"refresh", nextHash,
+				"last_seen", strconv.FormatInt(time.Now().Unix(), 10),
+				"ip", meta.IP,
+				"user_agent", meta.UserAgent,
+			)
+			pipe.Expire(ctx, key, ttl)
+			pipe.Expire(ctx, userSessionsKeyPrefix+userID, ttl)
+			pipe.Set(ctx, refreshKeyPrefix+nextHash, sid, ttl)
+			return nil
+		})
+		return err
+	}, key)
+
+	switch {
+	case errors.Is(err, ErrRefreshTokenReused):
+		if rerr := RevokeSession(userID, sid); rerr != nil {
+			return nil, rerr
+		}
+		return nil, err
+	case errors.Is(err, redis.TxFailedErr):
+		return nil, ErrInvalidRefreshToken
+	case err != nil:
+		return nil, err
+	}
+
+	return issuePair(userID, sid, next, ttl)
+}
+
+// issuePair mints the access token for a session and bundles it with the
+// refresh token.
+func issuePair(userID, sid, refresh string, ttl time.Duration) (*TokenPair, error) {
+	access, accessExp, err := GenerateJWT(userID, sid)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenPair{
+		SessionID:        sid,
+		AccessToken:      access,
+		AccessExpiresAt:  accessExp,
+		RefreshToken:     refresh,
+		RefreshExpiresAt: time.Now().Add(ttl),
+	}, nil
+}
+
+// sessionActive reports whether the session sid exists and belongs to
+// userID. Redis errors other than a missing key fail open, like rate
+// limiting, so an outage does not log every user out.
+func sessionActive(sid, userID string) bool {
+	if sid == "" {
+		return false
+	}
+	owner, err := storage.RedisClient.HGet(ctx, sessionKeyPrefix+sid, "user_id").Result()
+	if errors.Is(err, redis.Nil) {
+		return false
+	}
+	if err != nil {
+		return true
+	}
+	return owner == userID
+}
+
+// TouchSession records that the session was used from ip. Writes are
+// throttled to one per lastSeenInterval; errors are ignored since last_seen
+// is informational.
+func TouchSession(sid, ip string) {
+	if sid == "" {
+		return
+	}
+	first, err := storage.RedisClient.SetNX(ctx, sessionSeenKeyPrefix+sid, 1, lastSeenInterval).Result()
+	if err != nil || !first {
+		return
+	}
+	// HSET on a revoked session would recreate it without an expiry.
+	key := sessionKeyPrefix + sid
+	if n, err := storage.RedisClient.Exists(ctx, key).Result(); err != nil || n == 0 {
+		return
+	}
+	storage.RedisClient.HSet(ctx, key,
+		"last_seen", strconv.FormatInt(time.Now().Unix(), 10),
+		"ip", ip,
+	)
+}
+
+// ListSessions returns the active sessions of userID, most recently used
+// first. Expired sessions are pruned from the user's set as they are found.
+func ListSessions(userID string) ([]Session, error) {
+	setKey := userSessionsKeyPrefix + userID
+	sids, err := storage.RedisClient.SMembers(ctx, setKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	pipe := storage.RedisClient.Pipeline()
+	cmds := make([]*redis.StringStringMapCmd, len(sids))
+	for i, sid := range sids {
+		cmds[i] = pipe.HGetAll(ctx, sessionKeyPrefix+sid)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+
+	sessions := make([]Session, 0, len(sids))
+	var stale []interface{}
+	for i, cmd := range cmds {
+		vals := cmd.Val()
+		if len(vals) == 0 || vals["user_id"] != userID {
+			stale = append(stale, sids[i])
+			continue
+		}
+		sessions = append(sessions, Session{
+			ID:         sids[i],
+			UserID:     userID,
+			IP:         vals["ip"],
+			UserAgent:  vals["user_agent"],
+			CreatedAt:  unixField(vals["created_at"]),
+			LastSeenAt: unixField(vals["last_seen"]),
+		})
+	}
+	if len(stale) > 0 {
+		storage.RedisClient.SRem(ctx, setKey, stale...)
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt)
+	})
+	return sessions, nil
+}
+
+// RevokeUserSession ends session sid if it belongs to userID, returning
+// ErrSessionNotFound otherwise.
+func RevokeUserSession(userID, sid string) error {
+	owner, err := storage.RedisClient.HGet(ctx, sessionKeyPrefix+sid, "user_id").Result()
+	if errors.Is(err, redis.Nil) || (err == nil && owner != userID) {
+		return ErrSessionNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return RevokeSession(userID, sid)
+}
+
+// unixField parses a Unix-seconds hash field, returning the zero time if it
+// is missing.
+func unixField(v string) time.Time {
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(n, 0).UTC()
+}
+
+// RevokeSession ends a single session, e.g. on logout.
+func RevokeSession(userID, sid string) error {
+	pipe := storage.RedisClient.TxPipeline()
+	pipe.Del(ctx, sessionKeyPrefix+sid, sessionSeenKeyPrefix+sid)
+	pipe.SRem(ctx, userSessionsKeyPrefix+userID, sid)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// RevokeRefreshSession ends the session refreshToken belongs to. It is used
+// on logout when the access token has already expired. Unknown tokens are
+// ignored, so logging out twice is harmless.
+func RevokeRefreshSession(refreshToken string) error {
+	sid, err := storage.RedisClient.Get(ctx, refreshKeyPrefix+HashToken(refreshToken)).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	userID, err := storage.RedisClient.HGet(ctx, sessionKeyPrefix+sid, "user_id").Result()
+	if errors.Is(err, redis.Nil) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return RevokeSession(userID, sid)
+}
+
+// RevokeAllSessions ends every session of userID except keep, which may be
+// empty. It is used for "log out all devices" and after password changes.
+func RevokeAllSessions(userID, keep string) error {
+	setKey := userSessionsKeyPrefix + userID
+	sids, err := storage.RedisClient.SMembers(ctx, setKey).Result()
+	if err != nil {
+		return err
+	}
+
+	pipe := storage.RedisClient.TxPipeline()
+	for _, sid := range sids {
+		if sid == keep {
+			continue
+		}
+		pipe.Del(ctx, sessionKeyPrefix+sid)
+		pipe.SRem(ctx, setKey, sid)
+	}
+	_, err = pipe.Exec(ctx)
+	return err
+}
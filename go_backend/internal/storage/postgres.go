@@ -49,3 +49,11 @@ func GetPostgres() *sql.DB {
 	})
 	return db
 }
+
+// SetPostgres replaces the shared connection returned by GetPostgres. It is
+// meant for tests, which pass a mock or throwaway database instead of
+// POSTGRES_URL.
+func SetPostgres(conn *sql.DB) {
+	dbOnce.Do(func() {})
+	db = conn
+}
@@ -6,40 +6,103 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
-// SetAuthCookie sets a secure authentication cookie containing the JWT token.
-// It automatically adjusts domain, SameSite, and security settings depending
-// on whether the environment is local or production.
-func SetAuthCookie(c *gin.Context, token string) {
-	frontendOrigin := os.Getenv("FRONTEND_ORIGIN")
+// Auth cookie names. The refresh cookie is scoped to RefreshCookiePath so it
+// is only sent to the token refresh and logout endpoints.
+const (
+	AccessCookieName  = "token"
+	RefreshCookieName = "refresh_token"
+	RefreshCookiePath = "/api/auth"
+)
 
-	var cookieDomain string
-	var secure bool
-	var sameSite http.SameSite
+// LinkUnlockCookieName holds the token that opens a password-protected link.
+// Each link gets its own cookie, scoped to the link's path.
+const LinkUnlockCookieName = "link_unlock"
+
+// SetLinkUnlockCookie stores the unlock token for slug. Unlike the auth
+// cookies it is set on the redirect host itself, since short links are
+// opened there rather than on the frontend.
+func SetLinkUnlockCookie(c *gin.Context, slug, token string, expires time.Time) {
+	secure := c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(LinkUnlockCookieName, token, int(time.Until(expires).Seconds()), "/"+slug, "", secure, true)
+}
 
+// LinkVariantCookieName remembers the A/B variant a visitor was sent to by a
+// link with sticky variants. Like the unlock cookie it is scoped to the
+// link's path.
+const LinkVariantCookieName = "link_variant"
+
+// linkVariantCookieTTL is how long a visitor keeps their A/B variant.
+const linkVariantCookieTTL = 30 * 24 * time.Hour
+
+// SetLinkVariantCookie stores the A/B variant chosen for a visitor of slug on
+// the redirect host.
+func SetLinkVariantCookie(c *gin.Context, slug, variant string) {
+	secure := c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(LinkVariantCookieName, variant, int(linkVariantCookieTTL.Seconds()), "/"+slug, "", secure, true)
+}
+
+// SetAuthCookie sets a secure authentication cookie containing the access
+// JWT, expiring together with the token.
+func SetAuthCookie(c *gin.Context, token string, expires time.Time) {
+	setCookie(c, AccessCookieName, token, "/", expires)
+}
+
+// SetRefreshCookie sets the cookie carrying the opaque refresh token.
+func SetRefreshCookie(c *gin.Context, token string, expires time.Time) {
+	setCookie(c, RefreshCookieName, token, RefreshCookiePath, expires)
+}
+
+// SetAuthCookies sets both the access and refresh cookies.
+func SetAuthCookies(c *gin.Context, access string, accessExpires time.Time, refresh string, refreshExpires time.Time) {
+	SetAuthCookie(c, access, accessExpires)
+	SetRefreshCookie(c, refresh, refreshExpires)
+}
+
+// ClearAuthCookies removes both auth cookies by setting MaxAge < 0.
+func ClearAuthCookies(c *gin.Context) {
+	domain, secure, sameSite := cookieSettings()
+	c.SetSameSite(sameSite)
+	c.SetCookie(AccessCookieName, "", -1, "/", domain, secure, true)
+	c.SetCookie(RefreshCookieName, "", -1, RefreshCookiePath, domain, secure, true)
+}
+
+// setCookie writes an HttpOnly cookie that expires at the given time.
+func setCookie(c *gin.Context, name, value, path string, expires time.Time) {
+	maxAge := int(time.Until(expires).Seconds())
+	if maxAge <= 0 {
+		maxAge = -1
+	}
+
+	domain, secure, sameSite := cookieSettings()
+	c.SetSameSite(sameSite)
+	c.SetCookie(name, value, maxAge, path, domain, secure, true)
+}
+
+// cookieSettings automatically adjusts domain, SameSite, and security
+// settings depending on whether the environment is local or production.
+func cookieSettings() (domain string, secure bool, sameSite http.SameSite) {
+	frontendOrigin := os.Getenv("FRONTEND_ORIGIN")
 	if frontendOrigin == "" {
 		frontendOrigin = "http://localhost:3000"
 	}
 
 	if strings.Contains(frontendOrigin, "localhost") {
-		cookieDomain = "localhost"
-		secure = false
-		sameSite = http.SameSiteLaxMode
-	} else {
-		// Use .env override or fallback to domain parsed from origin.
-		cookieDomain = os.Getenv("COOKIE_DOMAIN")
-		if cookieDomain == "" {
-			cookieDomain = extractDomain(frontendOrigin)
-		}
-		secure = true
-		sameSite = http.SameSiteNoneMode
+		return "localhost", false, http.SameSiteLaxMode
 	}
 
-	c.SetSameSite(sameSite)
-	c.SetCookie("token", token, 30*24*60*60, "/", cookieDomain, secure, true)
+	// Use .env override or fallback to domain parsed from origin.
+	domain = os.Getenv("COOKIE_DOMAIN")
+	if domain == "" {
+		domain = extractDomain(frontendOrigin)
+	}
+	return domain, true, http.SameSiteNoneMode
 }
 
 // extractDomain removes protocol prefixes (http/https) and any path suffixes
@@ -0,0 +1,26 @@
+package utils
+
+import "strings"
+
+// csvEscapedPrefixes are the leading characters EscapeCSVFormula guards:
+// those that make spreadsheets evaluate a cell as a formula, and the quote
+// used as the guard itself so that escaping can always be undone.
+const csvEscapedPrefixes = "=+-@\t\r'"
+
+// EscapeCSVFormula prefixes v with a quote if a spreadsheet would evaluate
+// it as a formula, such as a visitor's referer starting with "=".
+func EscapeCSVFormula(v string) string {
+	if v != "" && strings.ContainsRune(csvEscapedPrefixes, rune(v[0])) {
+		return "'" + v
+	}
+	return v
+}
+
+// UnescapeCSVFormula removes the quote added by EscapeCSVFormula, so that
+// values such as the slug "-launch" survive an export and re-import.
+func UnescapeCSVFormula(v string) string {
+	if len(v) > 1 && v[0] == '\'' && strings.ContainsRune(csvEscapedPrefixes, rune(v[1])) {
+		return v[1:]
+	}
+	return v
+}
@@ -0,0 +1,30 @@
+package utils
+
+import "testing"
+
+func TestCSVFormulaRoundTrip(t *testing.T) {
+	tests := []struct {
+		in      string
+		escaped string
+	}{
+		{in: "", escaped: ""},
+		{in: "spring-sale", escaped: "spring-sale"},
+		{in: "-launch", escaped: "'-launch"},
+		{in: "=HYPERLINK(\"x\")", escaped: "'=HYPERLINK(\"x\")"},
+		{in: "+1", escaped: "'+1"},
+		{in: "@home", escaped: "'@home"},
+		{in: "\tindented", escaped: "'\tindented"},
+		{in: "'quoted", escaped: "''quoted"},
+		{in: "'=already", escaped: "''=already"},
+		{in: "it's", escaped: "it's"},
+	}
+	for _, tt := range tests {
+		escaped := EscapeCSVFormula(tt.in)
+		if escaped != tt.escaped {
+			t.Errorf("EscapeCSVFormula(%q) = %q, want %q", tt.in, escaped, tt.escaped)
+		}
+		if got := UnescapeCSVFormula(escaped); got != tt.in {
+			t.Errorf("UnescapeCSVFormula(%q) = %q, want %q", escaped, got, tt.in)
+		}
+	}
+}
@@ -13,8 +13,54 @@ import (
 const (
 	slugCharset     = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
 	maxSlugAttempts = 5
+
+	// MinSlugLength and MaxSlugLength mirror the CHECK constraint on urls.slug.
+	MinSlugLength = 4
+	MaxSlugLength = 100
 )
 
+// ErrInvalidSlug is returned by ValidateSlug for slugs that cannot be stored
+// or routed.
+var ErrInvalidSlug = errors.New("slug must be 4-100 characters of letters, digits, '-' or '_'")
+
+// ErrReservedSlug is returned by ValidateSlug for slugs that collide with a
+// server route.
+var ErrReservedSlug = errors.New("slug is reserved")
+
+// reservedSlugs are the top-level paths served by other routes (see
+// router.SetupRouter). Gin matches them before /:slug, so links with these
+// slugs would never redirect.
+var reservedSlugs = map[string]bool{
+	"api":             true,
+	"health":          true,
+	"login":           true,
+	"register":        true,
+	"shorten":         true,
+	"google":          true,
+	"favicon.ico":     true,
+	"forgot-password": true,
+	"reset-password":  true,
+	"verify-email":    true,
+	".well-known":     true,
+}
+
+// ValidateSlug checks a custom slug against the urls.slug CHECK constraint,
+// restricts it to URL-safe characters and rejects reserved route names.
+func ValidateSlug(slug string) error {
+	if reservedSlugs[strings.ToLower(slug)] {
+		return ErrReservedSlug
+	}
+	if len(slug) < MinSlugLength || len(slug) > MaxSlugLength {
+		return ErrInvalidSlug
+	}
+	for _, r := range slug {
+		if !strings.ContainsRune(slugCharset, r) && r != '-' && r != '_' {
+			return ErrInvalidSlug
+		}
+	}
+	return nil
+}
+
 // GenerateRandomSlug returns a cryptographically secure random Base62 slug of the specified length.
 func GenerateRandomSlug(length int) (string, error) {
 	slug := make([]byte, length)
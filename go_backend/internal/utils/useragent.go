@@ -0,0 +1,130 @@
+// Package utils provides helper functions for URL shortening,
+// user-agent parsing, and general utility operations.
+package utils
+
+import "strings"
+
+// UserAgentInfo holds the browser, operating system, and device class
+// parsed from a User-Agent header.
+type UserAgentInfo struct {
+	Browser string `json:"browser"`
+	OS      string `json:"os"`
+	Device  string `json:"device"` // desktop, mobile, tablet or bot
+}
+
+// uaToken maps a case-insensitive substring of a User-Agent to a display name.
+type uaToken struct {
+	match string
+	name  string
+}
+
+// Order matters: more specific tokens must come before generic ones
+// (e.g. Edge and Opera both advertise "Chrome", Chrome advertises "Safari").
+var (
+	browserTokens = []uaToken{
+		{"edg/", "Edge"},
+		{"edga/", "Edge"},
+		{"edgios/", "Edge"},
+		{"opr/", "Opera"},
+		{"opera", "Opera"},
+		{"samsungbrowser", "Samsung Internet"},
+		{"yabrowser", "Yandex"},
+		{"ucbrowser", "UC Browser"},
+		{"firefox/", "Firefox"},
+		{"fxios/", "Firefox"},
+		{"crios/", "Chrome"},
+		{"chrome/", "Chrome"},
+		{"chromium/", "Chrome"},
+		{"safari/", "Safari"},
+		{"msie ", "Internet Explorer"},
+		{"trident/", "Internet Explorer"},
+	}
+
+	osTokens = []uaToken{
+		{"windows", "Windows"},
+		{"iphone", "iOS"},
+		{"ipad", "iOS"},
+		{"ipod", "iOS"},
+		{"android", "Android"},
+		{"cros", "ChromeOS"},
+		{"mac os x", "macOS"},
+		{"macintosh", "macOS"},
+		{"linux", "Linux"},
+	}
+
+	botTokens = []string{
+		"bot", "crawler", "spider", "slurp", "facebookexternalhit",
+		"embedly", "preview", "curl", "wget", "python-requests", "go-http-client",
+	}
+)
+
+// ParseUserAgent performs a lightweight, dependency-free classification of
+// a User-Agent header. Unknown values are reported as "Other".
+func ParseUserAgent(ua string) UserAgentInfo {
+	info := UserAgentInfo{Browser: "Other", OS: "Other", Device: "desktop"}
+	if ua == "" {
+		return info
+	}
+	lower := strings.ToLower(ua)
+
+	for _, t := range browserTokens {
+		if strings.Contains(lower, t.match) {
+			info.Browser = t.name
+			break
+		}
+	}
+	for _, t := range osTokens {
+		if strings.Contains(lower, t.match) {
+			info.OS = t.name
+			break
+		}
+	}
+
+	switch {
+	case containsAny(lower, botTokens):
+		info.Device = "bot"
+	case strings.Contains(lower, "ipad") || strings.Contains(lower, "tablet") ||
+		(strings.Contains(lower, "android") && !strings.Contains(lower, "mobile")):
+		info.Device = "tablet"
+	case strings.Contains(lower, "mobi") || strings.Contains(lower, "iphone") || strings.Contains(lower, "ipod"):
+		info.Device = "mobile"
+	}
+
+	return info
+}
+
+// containsAny reports whether s contains any of the given substrings.
+func containsAny(s string, subs []string) bool {
+	for _, sub := range subs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// CanonicalBrowser returns the display name ParseUserAgent reports for a
+// browser, matched case-insensitively, and whether it is known.
+func CanonicalBrowser(name string) (string, bool) {
+	return canonicalName(browserTokens, name)
+}
+
+// CanonicalOS returns the display name ParseUserAgent reports for an
+// operating system, matched case-insensitively, and whether it is known.
+func CanonicalOS(name string) (string, bool) {
+	return canonicalName(osTokens, name)
+}
+
+// canonicalName looks name up among the display names of tokens and "Other".
+func canonicalName(tokens []uaToken, name string) (string, bool) {
+	name = strings.TrimSpace(name)
+	if strings.EqualFold(name, "Other") {
+		return "Other", true
+	}
+	for _, t := range tokens {
+		if strings.EqualFold(t.name, name) {
+			return t.name, true
+		}
+	}
+	return "", false
+}
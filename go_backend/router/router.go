@@ -9,6 +9,7 @@ package router
 import (
 	"net/http"
 
+	"go_backend/internal/analytics"
 	"go_backend/internal/handlers/auth"
 	"go_backend/internal/handlers/urls"
 	"go_backend/internal/handlers/users"
@@ -23,6 +24,7 @@ func SetupRouter(r *gin.Engine) *gin.Engine {
 	// Register global middleware.
 	r.Use(
 		middleware.CORSMiddleware(),
+		middleware.APIKeyMiddleware(),
 		middleware.BlockBadRequests(),
 		middleware.RateLimitMiddleware(),
 	)
@@ -34,28 +36,71 @@ func SetupRouter(r *gin.Engine) *gin.Engine {
 	r.GET("/health", func(c *gin.Context) {
 		c.String(http.StatusOK, "ok")
 	})
+	r.GET("/health/analytics", middleware.InternalTokenMiddleware(), func(c *gin.Context) {
+		c.JSON(http.StatusOK, analytics.CurrentStats())
+	})
 
 	// Register public URL routes.
 	r.POST("/shorten", urls.ShortenPublicURL)
 	r.GET("/:slug", urls.RedirectURL)
+	r.POST("/:slug/unlock", urls.UnlockURL)
 
 	// Register authentication routes.
 	r.GET("/google/login", auth.GoogleLogin)
 	r.GET("/google/callback", auth.GoogleCallback)
 	r.POST("/register", auth.Register)
 	r.POST("/login", auth.Login)
+	r.POST("/forgot-password", auth.ForgotPassword)
+	r.POST("/reset-password", auth.ResetPassword)
+	r.POST("/verify-email", auth.VerifyEmail)
+	r.POST("/verify-email/resend", auth.ResendVerification)
+	r.GET("/.well-known/jwks.json", auth.JWKS)
 	
 	// Register protected API routes.
 	api := r.Group("/api")
 	{
 		api.POST("/publicshorturl", urls.ShortenPublicURL)
 		api.POST("/set-cookie", auth.SetCookieHandler)
+		api.POST("/auth/refresh", auth.Refresh)
+		api.POST("/auth/logout", auth.Logout)
 		api.GET("/validate", auth.Validate)
-		api.POST("/logout", middleware.AuthMiddleware(), auth.Logout)
-		api.POST("/user/shorten", middleware.AuthMiddleware(), urls.ShortenURL)
-		api.POST("/delete/shortlink", middleware.AuthMiddleware(), urls.DeleteShortlink)
+		api.POST("/logout-all", middleware.AuthMiddleware(), auth.LogoutAll)
+		api.POST("/user/change-password", middleware.AuthMiddleware(), auth.ChangePassword)
+		api.POST("/user/shorten", middleware.APIKeyOrAuthMiddleware(), urls.ShortenURL)
+		api.POST("/user/shorten/bulk", middleware.APIKeyOrAuthMiddleware(), urls.BulkShortenURLs)
+		api.POST("/user/import", middleware.APIKeyOrAuthMiddleware(), urls.ImportLinks)
+		api.POST("/update/shortlink", middleware.APIKeyOrAuthMiddleware(), urls.UpdateShortlink)
+		api.POST("/delete/shortlink", middleware.APIKeyOrAuthMiddleware(), urls.DeleteShortlink)
 		api.GET("/user/details", middleware.AuthMiddleware(), users.GetUserDetails)
-		api.GET("/user/shortlinks", middleware.AuthMiddleware(), users.GetUserShortLinks)
+		api.GET("/user/plan", middleware.APIKeyOrAuthMiddleware(), users.GetUserPlanLimits)
+		api.GET("/user/sessions", middleware.AuthMiddleware(), users.GetUserSessions)
+		api.DELETE("/user/sessions/:id", middleware.AuthMiddleware(), users.RevokeUserSession)
+		api.GET("/user/shortlinks", middleware.APIKeyOrAuthMiddleware(), users.GetUserShortLinks)
+		api.GET("/user/export/links", middleware.APIKeyOrAuthMiddleware(), users.ExportUserLinks)
+		api.GET("/user/export/visits", middleware.APIKeyOrAuthMiddleware(), users.ExportUserVisits)
+		api.POST("/user/api-keys", middleware.AuthMiddleware(), users.CreateAPIKey)
+		api.GET("/user/api-keys", middleware.AuthMiddleware(), users.GetAPIKeys)
+		api.DELETE("/user/api-keys/:id", middleware.AuthMiddleware(), users.RevokeAPIKey)
+		api.GET("/user/tags", middleware.APIKeyOrAuthMiddleware(), users.GetTags)
+		api.POST("/user/tags", middleware.APIKeyOrAuthMiddleware(), users.CreateTag)
+		api.PATCH("/user/tags/:id", middleware.APIKeyOrAuthMiddleware(), users.RenameTag)
+		api.DELETE("/user/tags/:id", middleware.APIKeyOrAuthMiddleware(), users.DeleteTag)
+		api.GET("/user/tags/:id/analytics", middleware.APIKeyOrAuthMiddleware(), urls.GetTagAnalytics)
+		api.GET("/user/tags/:id/analytics/timeseries", middleware.APIKeyOrAuthMiddleware(), urls.GetTagTimeSeries)
+		api.GET("/user/folders", middleware.APIKeyOrAuthMiddleware(), users.GetFolders)
+		api.POST("/user/folders", middleware.APIKeyOrAuthMiddleware(), users.CreateFolder)
+		api.PATCH("/user/folders/:id", middleware.APIKeyOrAuthMiddleware(), users.RenameFolder)
+		api.DELETE("/user/folders/:id", middleware.APIKeyOrAuthMiddleware(), users.DeleteFolder)
+		api.GET("/user/folders/:id/analytics", middleware.APIKeyOrAuthMiddleware(), urls.GetFolderAnalytics)
+		api.GET("/user/folders/:id/analytics/timeseries", middleware.APIKeyOrAuthMiddleware(), urls.GetFolderTimeSeries)
+
+		// Link-scoped routes: ownership of :id is checked before the handler runs.
+		// API keys are accepted here; session and key management stay JWT-only.
+		link := api.Group("/user/shortlinks/:id", middleware.APIKeyOrAuthMiddleware(), middleware.RequireLinkOwner())
+		link.GET("/analytics", urls.GetLinkAnalytics)
+		link.GET("/analytics/timeseries", urls.GetLinkTimeSeries)
+		link.POST("/qrcode", urls.CreateQRCode)
+		link.GET("/qrcode", urls.GetQRCode)
 	}
 
 	// Ignore favicon requests.
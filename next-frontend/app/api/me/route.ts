@@ -7,7 +7,7 @@
 
 import { NextResponse } from "next/server";
 import { cookies } from "next/headers";
-import { jwtVerify } from "jose";
+import { verifyAccessToken } from "@/lib/jwt";
 
 export async function GET() {
   // Read cookie value
@@ -20,10 +20,7 @@ export async function GET() {
   }
 
   try {
-    const { payload } = await jwtVerify(
-      token,
-      new TextEncoder().encode(process.env.JWT_SECRET!)
-    );
+    const payload = await verifyAccessToken(token);
 
     const allowed = process.env.ALLOWED_USER_IDS?.split(",") ?? [];
 
@@ -2,8 +2,8 @@
 
 /**
  * Handles authentication callback after external login.
- * Reads token and origin from URL, forwards token to backend to set cookie,
- * verifies authentication, and redirects user based on context.
+ * The backend has already set the session cookies; this verifies
+ * authentication and redirects user based on context.
  */
 
 "use client";
@@ -16,39 +16,16 @@ export default function AuthCallbackClient() {
   const searchParams = useSearchParams();
 
   useEffect(() => {
-    const token = searchParams.get("token");
     const from = searchParams.get("from");
 
-    // Redirect if token is not present
-    if (!token) {
-      router.push("/");
-      return;
-    }
-
-    // Forward token to backend to create auth cookie
-    fetch("/api/auth/set-cookie", {
-      method: "POST",
+    // Validate authentication via backend user endpoint
+    fetch(`${process.env.NEXT_PUBLIC_BACKEND_URL}/api/user/details`, {
+      method: "GET",
       credentials: "include",
-      headers: { "Content-Type": "application/json" },
-      body: JSON.stringify({ token }),
     })
-      .then(async (res) => {
-        if (!res.ok) {
-          router.push("/");
-          return;
-        }
-
-        // Validate authentication via backend user endpoint
-        const verifyRes = await fetch(
-          `${process.env.NEXT_PUBLIC_BACKEND_URL}/api/user/details`,
-          {
-            method: "GET",
-            credentials: "include",
-          }
-        );
-
+      .then(async (verifyRes) => {
         if (!verifyRes.ok) {
-          router.push("/dashboard");
+          router.push("/");
           return;
         }
 
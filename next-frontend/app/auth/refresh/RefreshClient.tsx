@@ -0,0 +1,64 @@
+// next-frontend/app/auth/refresh/RefreshClient.tsx
+
+/**
+ * Renews an expired session.
+ * proxy.ts sends protected routes here when the access token cookie is
+ * missing or expired. The refresh cookie is only sent to the backend, so the
+ * renewal happens in the browser before returning to the original page.
+ */
+
+"use client";
+
+import { useEffect } from "react";
+import { useRouter, useSearchParams } from "next/navigation";
+
+import { refreshSession } from "@/utils/refreshSession";
+
+// Guards against a redirect loop when fresh cookies still fail validation.
+const LAST_REFRESH_KEY = "auth-refresh-at";
+const MIN_REFRESH_INTERVAL_MS = 10_000;
+
+export default function RefreshClient() {
+  const router = useRouter();
+  const searchParams = useSearchParams();
+
+  useEffect(() => {
+    // Only return to local paths
+    const next = searchParams.get("next") ?? "";
+    const target = next.startsWith("/") && !next.startsWith("//") ? next : "/dashboard";
+
+    const last = Number(sessionStorage.getItem(LAST_REFRESH_KEY) ?? 0);
+    if (Date.now() - last < MIN_REFRESH_INTERVAL_MS) {
+      sessionStorage.removeItem(LAST_REFRESH_KEY);
+      router.replace("/");
+      return;
+    }
+
+    refreshSession().then((ok) => {
+      if (!ok) {
+        router.replace("/");
+        return;
+      }
+      sessionStorage.setItem(LAST_REFRESH_KEY, String(Date.now()));
+      // Full navigation so proxy.ts sees the new cookie
+      window.location.replace(target);
+    });
+  }, [searchParams, router]);
+
+  return (
+    <div className="min-h-screen flex items-center justify-center bg-background text-foreground p-4">
+      <div className="flex flex-col items-center p-8 bg-card rounded-lg shadow-lg max-w-sm w-full">
+        <div
+          className="w-15 h-30 border-4 border-t-transparent rounded-full animate-spin border-r-pink-500 border-b-purple-500 border-l-indigo-500 mb-6"
+          role="status"
+        >
+          <span className="sr-only">Loading...</span>
+        </div>
+
+        <h2 className="text-2xl font-semibold text-muted-foreground mb-2">
+          Restoring your session...
+        </h2>
+      </div>
+    </div>
+  );
+}
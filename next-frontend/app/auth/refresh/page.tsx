@@ -0,0 +1,17 @@
+// app/auth/refresh/page.tsx
+
+/**
+ * Wraps the session refresh client component in React Suspense.
+ * The child component renews the session and redirects back.
+ */
+
+import { Suspense } from "react";
+import RefreshClient from "./RefreshClient";
+
+export default function Page() {
+  return (
+    <Suspense>
+      <RefreshClient />
+    </Suspense>
+  );
+}
@@ -9,6 +9,7 @@
  *   • Copy short link
  *   • Delete link
  *   • View visits + last activity
+ *   • Load more links page by page
  */
 
 "use client";
@@ -17,6 +18,8 @@ import { useEffect, useState } from "react";
 import { format } from "date-fns";
 import { useRouter } from "next/navigation";
 
+import { fetchWithRefresh } from "@/utils/refreshSession";
+
 import {
   Card,
   CardContent,
@@ -25,6 +28,7 @@ import {
 } from "@/components/ui/card";
 import { Badge } from "@/components/ui/badge";
 import { Skeleton } from "@/components/ui/skeleton";
+import { Button } from "@/components/ui/button";
 
 import {
   Tooltip,
@@ -73,25 +77,45 @@ interface ShortLink {
   last_clicked_at: string | null;
 }
 
+/**
+ * One page of the user links response
+ */
+interface ShortLinksPage {
+  links: ShortLink[];
+  total: number;
+  next_cursor?: string | null;
+}
+
 export default function ShortLinksList() {
   const [links, setLinks] = useState<ShortLink[] | null>(null);
   const [loading, setLoading] = useState<boolean>(true);
+  const [nextCursor, setNextCursor] = useState<string | null>(null);
+  const [loadingMore, setLoadingMore] = useState<boolean>(false);
   const [copiedMap, setCopiedMap] = useState<Record<string, boolean>>({});
   const router = useRouter();
 
   /**
-   * Fetches authenticated user short links
+   * Fetches one page of authenticated user short links
    */
+  const fetchPage = async (cursor?: string): Promise<ShortLinksPage> => {
+    const url = new URL(`${API_BASE_URL}${USER_SHORT_LINKS_ENDPOINT}`);
+    if (cursor) {
+      url.searchParams.set("cursor", cursor);
+    }
+    const response = await fetchWithRefresh(url.toString());
+
+    if (!response.ok) {
+      throw new Error(await response.text());
+    }
+    return response.json();
+  };
+
   useEffect(() => {
     const fetchLinks = async () => {
       try {
-        const url = new URL(`${API_BASE_URL}${USER_SHORT_LINKS_ENDPOINT}`);
-        const response = await fetch(url.toString(), {
-          credentials: "include",
-        });
-
-        const data = await response.json();
-        setLinks(data);
+        const page = await fetchPage();
+        setLinks(page.links ?? []);
+        setNextCursor(page.next_cursor ?? null);
       } catch (error) {
         console.error("Failed to fetch links:", error);
       } finally {
@@ -102,17 +126,34 @@ export default function ShortLinksList() {
     fetchLinks();
   }, []);
 
+  /**
+   * Appends the next page of links
+   */
+  const handleLoadMore = async () => {
+    if (!nextCursor) return;
+
+    setLoadingMore(true);
+    try {
+      const page = await fetchPage(nextCursor);
+      setLinks((prev) => [...(prev ?? []), ...(page.links ?? [])]);
+      setNextCursor(page.next_cursor ?? null);
+    } catch (error) {
+      console.error("Failed to fetch more links:", error);
+    } finally {
+      setLoadingMore(false);
+    }
+  };
+
   /**
    * Removes a short link
    */
   const handleDelete = async (id: string) => {
     try {
-      const response = await fetch(
+      const response = await fetchWithRefresh(
         `${BACKEND_BASE_URL}${DELETE_ENDPOINT}`,
         {
           method: "POST",
           headers: { "Content-Type": "application/json" },
-          credentials: "include",
           body: JSON.stringify({ id }),
         }
       );
@@ -330,6 +371,16 @@ export default function ShortLinksList() {
             No links found.
           </p>
         )}
+
+        {!loading && nextCursor && (
+          <Button
+            variant="outline"
+            onClick={handleLoadMore}
+            disabled={loadingMore}
+          >
+            {loadingMore ? "Loading..." : "Load more"}
+          </Button>
+        )}
       </CardContent>
     </Card>
   );
@@ -0,0 +1,58 @@
+// lib/jwt.ts
+
+/**
+ * Access token verification for server code (proxy.ts, API routes).
+ *
+ * Tokens with a `kid` header are signed with the backend's asymmetric keys
+ * (RS256 / EdDSA) and checked against its JWKS at
+ * `/.well-known/jwks.json`, which jose caches and refetches on unknown kids.
+ * Tokens without a `kid` are HS256 tokens checked against JWT_SECRET, which
+ * the backend keeps accepting while it is set.
+ */
+
+import {
+  createRemoteJWKSet,
+  decodeProtectedHeader,
+  jwtVerify,
+  type JWTPayload as JoseJWTPayload,
+} from "jose";
+
+export type JWTPayload = JoseJWTPayload & {
+  user_id?: string;
+};
+
+const jwks = createRemoteJWKSet(
+  new URL(
+    "/.well-known/jwks.json",
+    process.env.NEXT_PUBLIC_BACKEND_URL ?? "http://localhost:8080"
+  )
+);
+
+const hmacSecret = process.env.JWT_SECRET
+  ? new TextEncoder().encode(process.env.JWT_SECRET)
+  : null;
+
+/**
+ * Verifies an access token.
+ *
+ * @throws Error when the token is invalid, expired or signed with an
+ *   unknown key.
+ */
+export async function verifyAccessToken(token: string): Promise<JWTPayload> {
+  const { kid } = decodeProtectedHeader(token);
+
+  if (kid) {
+    const { payload } = await jwtVerify(token, jwks, {
+      algorithms: ["RS256", "EdDSA"],
+    });
+    return payload;
+  }
+
+  if (!hmacSecret) {
+    throw new Error("token has no kid and JWT_SECRET is not set");
+  }
+  const { payload } = await jwtVerify(token, hmacSecret, {
+    algorithms: ["HS256"],
+  });
+  return payload;
+}
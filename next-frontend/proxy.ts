@@ -4,30 +4,23 @@
  * This file handles authentication and route protection for the Next.js app.
  * It performs the following:
  *  - Validates JWT tokens for protected routes
+ *  - Sends expired sessions to /auth/refresh to be renewed
  *  - Redirects users from the root path to /dashboard if authenticated
  *  - Cleans up referral query parameters for SEO purposes
  */
 
 // middleware.ts
 import { NextRequest, NextResponse } from "next/server";
-import { jwtVerify } from "jose";
-
-type JWTPayload = {
-  user_id?: string;  
-  exp?: number;
-  iat?: number;
-};
-
-const secret = new TextEncoder().encode(process.env.JWT_SECRET!);
+import { verifyAccessToken, type JWTPayload } from "@/lib/jwt";
 
 // Allowed user_ids (comma-separated in .env)
 // const allowedUserIds = (process.env.ALLOWED_USER_IDS || "").split(",");
 
 async function verifyToken(token: string): Promise<JWTPayload | null> {
   try {
-    const { payload } = await jwtVerify(token, secret);
+    const payload = await verifyAccessToken(token);
     // console.log("JWT payload:", payload);
-    return payload as JWTPayload;
+    return payload;
   } catch (e) {
     console.error(" JWT validation failed:", e);
     return null;
@@ -64,7 +57,11 @@ export async function proxy(request: NextRequest) {
   if (pathname.startsWith("/dashboard")) {
     const payload = token ? await verifyToken(token) : null;
     if (!payload) {
-      url.pathname = "/";
+      // The refresh cookie is scoped to the backend, so renew in the browser
+      const next = pathname + url.search;
+      url.pathname = "/auth/refresh";
+      url.search = "";
+      url.searchParams.set("next", next);
       return NextResponse.redirect(url);
     }
 
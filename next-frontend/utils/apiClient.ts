@@ -7,6 +7,7 @@
  *   - Basic error handling
  *   - Rate-limit (429) notification via toast
  *   - JSON parsing w/ fallback
+ *   - Session refresh and one retry on 401
  *
  * NOTE: This helper is intentionally lightweight. For advanced usage
  * (automatic reauth, retry queue, cancellation, abort signals),
@@ -14,6 +15,7 @@
  */
 
 import { toast } from "sonner";
+import { refreshSession } from "@/utils/refreshSession";
 
 interface ApiErrorPayload {
   retry_after?: number | string;
@@ -32,10 +34,16 @@ export async function apiFetch<T = unknown>(
   url: string,
   options?: RequestInit
 ): Promise<T> {
-  const response = await fetch(url, {
+  const init: RequestInit = {
     credentials: "include", // default for cookie-based auth
     ...options,
-  });
+  };
+  let response = await fetch(url, init);
+
+  // Renew an expired access token once and retry
+  if (response.status === 401 && (await refreshSession())) {
+    response = await fetch(url, init);
+  }
 
   // Handle rate limit (429)
   if (response.status === 429) {
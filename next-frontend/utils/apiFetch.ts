@@ -5,6 +5,7 @@
  *
  * Behavior:
  *  - Always includes cookies by default (`credentials: "include"`).
+ *  - On HTTP 401, renews the session once and retries (see refreshSession).
  *  - On HTTP 429 (rate limit), shows a toast with server-provided retry hint (if any) and throws.
  *  - On non-OK responses, surfaces status + raw text in a toast and throws.
  *  - On success, returns `res.json()` (the parsed JSON body).
@@ -16,13 +17,11 @@
  */
 
 import { toast } from "sonner";
+import { fetchWithRefresh } from "@/utils/refreshSession";
 
 export async function apiFetch(url: string, options?: RequestInit) {
-  // Include cookies on every request unless explicitly overridden by the caller.
-  const res = await fetch(url, {
-    ...options,
-    credentials: "include",
-  });
+  // Include cookies on every request; an expired access token is renewed once.
+  const res = await fetchWithRefresh(url, options);
 
   // Rate limit handling — expect optional `retry_after` in JSON body.
   if (res.status === 429) {
@@ -0,0 +1,52 @@
+// utils/refreshSession.ts
+
+/**
+ * Session refresh helpers.
+ *
+ * Access tokens are short-lived; the backend renews them from the
+ * HttpOnly refresh cookie at POST /api/auth/refresh and sets fresh cookies.
+ *
+ * Behavior:
+ *  - Concurrent callers share one refresh request, since every refresh
+ *    token can be used only once.
+ *  - `fetchWithRefresh` retries a request once after a 401 if the refresh
+ *    succeeded.
+ */
+
+const REFRESH_URL = `${process.env.NEXT_PUBLIC_BACKEND_URL ?? ""}/api/auth/refresh`;
+
+let pending: Promise<boolean> | null = null;
+
+/**
+ * Renews the session cookies. Resolves to false when the session is gone.
+ */
+export function refreshSession(): Promise<boolean> {
+  if (!pending) {
+    pending = fetch(REFRESH_URL, {
+      method: "POST",
+      credentials: "include",
+    })
+      .then((res) => res.ok)
+      .catch(() => false)
+      .finally(() => {
+        pending = null;
+      });
+  }
+  return pending;
+}
+
+/**
+ * `fetch` with cookies that renews the session once on 401.
+ */
+export async function fetchWithRefresh(
+  url: string,
+  options?: RequestInit
+): Promise<Response> {
+  const init: RequestInit = { ...options, credentials: "include" };
+
+  const res = await fetch(url, init);
+  if (res.status !== 401 || !(await refreshSession())) {
+    return res;
+  }
+  return fetch(url, init);
+}